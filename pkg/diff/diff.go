@@ -0,0 +1,293 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Edit is one step of turning the old text into the new one.
+type Edit struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines compares a and b line by line. Each edit holds one line, without
+// its line break.
+func Lines(a, b string) []Edit {
+	return compare(splitLines(a), splitLines(b))
+}
+
+// Words compares a and b word by word, treating runs of whitespace as
+// words of their own. Neighbouring edits of the same kind are merged, so
+// joining the texts of the equal and delete edits gives back a, and of the
+// equal and insert edits gives back b.
+func Words(a, b string) []Edit {
+	edits := compare(splitWords(a), splitWords(b))
+
+	merged := make([]Edit, 0, len(edits))
+	for _, edit := range edits {
+		if n := len(merged); n > 0 && merged[n-1].Op == edit.Op {
+			merged[n-1].Text += edit.Text
+			continue
+		}
+		merged = append(merged, edit)
+	}
+	return merged
+}
+
+// Unified renders the line differences between a and b as a unified diff
+// with context lines around each change. It returns "" if they are equal.
+func Unified(fromName, toName, a, b string, context int) string {
+	edits := Lines(a, b)
+
+	var out strings.Builder
+	for _, h := range hunks(edits, context) {
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(h.fromLine, h.fromCount), hunkRange(h.toLine, h.toCount))
+		for _, edit := range edits[h.start:h.end] {
+			switch edit.Op {
+			case Equal:
+				out.WriteString(" ")
+			case Delete:
+				out.WriteString("-")
+			case Insert:
+				out.WriteString("+")
+			}
+			out.WriteString(edit.Text)
+			out.WriteString("\n")
+		}
+	}
+	return out.String()
+}
+
+type hunk struct {
+	start, end          int // edits[start:end]
+	fromLine, fromCount int
+	toLine, toCount     int
+}
+
+// hunks groups the changes in edits with up to context equal lines on
+// either side; changes closer than twice that share a hunk.
+func hunks(edits []Edit, context int) []hunk {
+	var result []hunk
+
+	fromLine, toLine := 0, 0
+	fromAt := make([]int, len(edits)+1)
+	toAt := make([]int, len(edits)+1)
+	for i, edit := range edits {
+		fromAt[i], toAt[i] = fromLine, toLine
+		if edit.Op != Insert {
+			fromLine++
+		}
+		if edit.Op != Delete {
+			toLine++
+		}
+	}
+	fromAt[len(edits)], toAt[len(edits)] = fromLine, toLine
+
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+
+		start := max(i-context, 0)
+		end := i
+		for end < len(edits) {
+			if edits[end].Op != Equal {
+				end++
+				continue
+			}
+			next := end
+			for next < len(edits) && edits[next].Op == Equal {
+				next++
+			}
+			if next == len(edits) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+		stop := min(end+context, len(edits))
+
+		result = append(result, hunk{
+			start:     start,
+			end:       stop,
+			fromLine:  fromAt[start],
+			fromCount: fromAt[stop] - fromAt[start],
+			toLine:    toAt[start],
+			toCount:   toAt[stop] - toAt[start],
+		})
+		i = stop
+	}
+	return result
+}
+
+// hunkRange formats a hunk's line range; line is zero-based.
+func hunkRange(line, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", line)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", line+1)
+	}
+	return fmt.Sprintf("%d,%d", line+1, count)
+}
+
+// maxDistance caps the number of differences compare looks for, which
+// bounds its time and memory on texts that have little in common.
+const maxDistance = 1000
+
+// compare finds the shortest edit script between a and b with Myers'
+// algorithm. Only the part of each round's frontier that can be reached is
+// kept, so memory grows with the square of the number of differences
+// rather than with the size of the input. Past maxDistance differences it
+// gives up and replaces everything between the common prefix and suffix.
+func compare(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil
+	}
+
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+	for d := 0; d <= n+m; d++ {
+		if d > maxDistance {
+			return replace(a, b)
+		}
+
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, a, b)
+			}
+		}
+	}
+	return nil
+}
+
+// replace turns a into b by deleting and inserting everything except
+// their common prefix and suffix.
+func replace(a, b []string) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b)-prefix-suffix)
+	for _, text := range a[:prefix] {
+		edits = append(edits, Edit{Op: Equal, Text: text})
+	}
+	for _, text := range a[prefix : len(a)-suffix] {
+		edits = append(edits, Edit{Op: Delete, Text: text})
+	}
+	for _, text := range b[prefix : len(b)-suffix] {
+		edits = append(edits, Edit{Op: Insert, Text: text})
+	}
+	for _, text := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Op: Equal, Text: text})
+	}
+	return edits
+}
+
+func backtrack(trace [][]int, a, b []string) []Edit {
+	var edits []Edit
+
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		frontier := func(k int) int { return trace[d][k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && frontier(k-1) < frontier(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := frontier(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, Edit{Op: Equal, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, Edit{Op: Insert, Text: b[y-1]})
+			} else {
+				edits = append(edits, Edit{Op: Delete, Text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n"), "\n")
+}
+
+func splitWords(s string) []string {
+	var words []string
+	start := 0
+	runes := []rune(s)
+	for i := 1; i <= len(runes); i++ {
+		if i == len(runes) || unicode.IsSpace(runes[i]) != unicode.IsSpace(runes[i-1]) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return words
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,259 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Edit
+	}{
+		{
+			name: "both empty",
+		},
+		{
+			name: "equal",
+			a:    "one\ntwo\n",
+			b:    "one\ntwo",
+			want: []Edit{{Equal, "one"}, {Equal, "two"}},
+		},
+		{
+			name: "windows line breaks",
+			a:    "one\r\ntwo\r\n",
+			b:    "one\ntwo\n",
+			want: []Edit{{Equal, "one"}, {Equal, "two"}},
+		},
+		{
+			name: "from empty",
+			b:    "one\ntwo",
+			want: []Edit{{Insert, "one"}, {Insert, "two"}},
+		},
+		{
+			name: "to empty",
+			a:    "one\ntwo",
+			want: []Edit{{Delete, "one"}, {Delete, "two"}},
+		},
+		{
+			name: "insert",
+			a:    "one\nthree",
+			b:    "one\ntwo\nthree",
+			want: []Edit{{Equal, "one"}, {Insert, "two"}, {Equal, "three"}},
+		},
+		{
+			name: "delete",
+			a:    "one\ntwo\nthree",
+			b:    "one\nthree",
+			want: []Edit{{Equal, "one"}, {Delete, "two"}, {Equal, "three"}},
+		},
+		{
+			name: "change",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: []Edit{{Equal, "one"}, {Delete, "two"}, {Insert, "2"}, {Equal, "three"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Edit
+	}{
+		{
+			name: "equal",
+			a:    "the quick fox",
+			b:    "the quick fox",
+			want: []Edit{{Equal, "the quick fox"}},
+		},
+		{
+			name: "changed word",
+			a:    "the quick fox",
+			b:    "the slow fox",
+			want: []Edit{{Equal, "the "}, {Delete, "quick"}, {Insert, "slow"}, {Equal, " fox"}},
+		},
+		{
+			name: "added words",
+			a:    "the fox",
+			b:    "the quick brown fox",
+			want: []Edit{{Equal, "the "}, {Insert, "quick brown "}, {Equal, "fox"}},
+		},
+		{
+			name: "changed whitespace",
+			a:    "the fox",
+			b:    "the\n\tfox",
+			want: []Edit{{Equal, "the"}, {Delete, " "}, {Insert, "\n\t"}, {Equal, "fox"}},
+		},
+		{
+			name: "multibyte characters",
+			a:    "grüße aus köln",
+			b:    "grüße aus münchen",
+			want: []Edit{{Equal, "grüße aus "}, {Delete, "köln"}, {Insert, "münchen"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Words(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+
+			var a, b strings.Builder
+			for _, edit := range got {
+				if edit.Op != Insert {
+					a.WriteString(edit.Text)
+				}
+				if edit.Op != Delete {
+					b.WriteString(edit.Text)
+				}
+			}
+			if a.String() != tt.a || b.String() != tt.b {
+				t.Errorf("edits give back %q and %q", a.String(), b.String())
+			}
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{
+			name:    "equal",
+			a:       "one\ntwo\n",
+			b:       "one\ntwo\n",
+			context: 3,
+			want:    "",
+		},
+		{
+			name:    "from empty",
+			b:       "one\n",
+			context: 3,
+			want: "--- a\n+++ b\n" +
+				"@@ -0,0 +1 @@\n" +
+				"+one\n",
+		},
+		{
+			name:    "change with context",
+			a:       "1\n2\n3\n4\n5\n",
+			b:       "1\n2\nthree\n4\n5\n",
+			context: 1,
+			want: "--- a\n+++ b\n" +
+				"@@ -2,3 +2,3 @@\n" +
+				" 2\n" +
+				"-3\n" +
+				"+three\n" +
+				" 4\n",
+		},
+		{
+			name:    "nearby changes share a hunk",
+			a:       "1\n2\n3\n4\n5\n",
+			b:       "one\n2\n3\nfour\n5\n",
+			context: 1,
+			want: "--- a\n+++ b\n" +
+				"@@ -1,5 +1,5 @@\n" +
+				"-1\n" +
+				"+one\n" +
+				" 2\n" +
+				" 3\n" +
+				"-4\n" +
+				"+four\n" +
+				" 5\n",
+		},
+		{
+			name:    "distant changes get their own hunks",
+			a:       "1\n2\n3\n4\n5\n6\n7\n",
+			b:       "one\n2\n3\n4\n5\n6\nseven\n",
+			context: 1,
+			want: "--- a\n+++ b\n" +
+				"@@ -1,2 +1,2 @@\n" +
+				"-1\n" +
+				"+one\n" +
+				" 2\n" +
+				"@@ -6,2 +6,2 @@\n" +
+				" 6\n" +
+				"-7\n" +
+				"+seven\n",
+		},
+		{
+			name:    "deleted line without context",
+			a:       "1\n2\n3\n",
+			b:       "1\n3\n",
+			context: 0,
+			want: "--- a\n+++ b\n" +
+				"@@ -2 +1,0 @@\n" +
+				"-2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Unified("a", "b", tt.a, tt.b, tt.context)
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinesDistanceCap(t *testing.T) {
+	// Every other line changes, so the shortest script keeps the shared
+	// lines and needs two edits per pair.
+	lines := func(pairs int, changed string) string {
+		var lines []string
+		for i := 0; i < pairs; i++ {
+			lines = append(lines, fmt.Sprintf("shared %d", i), fmt.Sprintf("%s %d", changed, i))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	t.Run("below the cap", func(t *testing.T) {
+		edits := Lines(lines(maxDistance/2, "old"), lines(maxDistance/2, "new"))
+
+		equal := 0
+		for _, edit := range edits {
+			if edit.Op == Equal {
+				equal++
+			}
+		}
+		if equal != maxDistance/2 {
+			t.Errorf("got %d equal lines, want %d", equal, maxDistance/2)
+		}
+	})
+
+	t.Run("above the cap", func(t *testing.T) {
+		a := splitLines(lines(maxDistance/2+1, "old"))
+		b := splitLines(lines(maxDistance/2+1, "new"))
+
+		want := []Edit{{Equal, "shared 0"}}
+		for _, text := range a[1:] {
+			want = append(want, Edit{Delete, text})
+		}
+		for _, text := range b[1:] {
+			want = append(want, Edit{Insert, text})
+		}
+
+		edits := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+		if !reflect.DeepEqual(edits, want) {
+			t.Errorf("got %d edits, want the %d lines after the common prefix replaced as one block", len(edits), len(a)-1)
+		}
+	})
+}
@@ -1,12 +1,21 @@
 package hash
 
 import (
+	"errors"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var ErrUnsupportedHash = errors.New("unsupported password hash format")
+
 type PasswordHasher interface {
 	Hash(password string) (string, error)
+	// Verify reports whether password matches hash. It returns
+	// ErrUnsupportedHash if hash was not produced by this algorithm.
+	Verify(password, hash string) (bool, error)
+	// NeedsRehash reports whether hash was produced by a different algorithm
+	// or with different parameters than the hasher is configured with.
+	NeedsRehash(hash string) bool
 }
 
 type bcryptHasher struct {
@@ -30,3 +39,58 @@ func (h *bcryptHasher) Hash(password string) (string, error) {
 	hash := fmt.Sprintf("%s", hashBytes)
 	return hash, nil
 }
+
+func (h *bcryptHasher) Verify(password, hash string) (bool, error) {
+	if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+		return false, ErrUnsupportedHash
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err != nil {
+		switch {
+		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (h *bcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+	return cost != h.cost
+}
+
+type upgradingHasher struct {
+	current PasswordHasher
+	legacy  []PasswordHasher
+}
+
+// NewUpgradingHasher hashes with current but still verifies hashes produced
+// by any of the legacy hashers, so stored hashes can be migrated on sign-in.
+func NewUpgradingHasher(current PasswordHasher, legacy ...PasswordHasher) *upgradingHasher {
+	return &upgradingHasher{current: current, legacy: legacy}
+}
+
+func (h *upgradingHasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+func (h *upgradingHasher) Verify(password, hash string) (bool, error) {
+	for _, hasher := range append([]PasswordHasher{h.current}, h.legacy...) {
+		ok, err := hasher.Verify(password, hash)
+		if errors.Is(err, ErrUnsupportedHash) {
+			continue
+		}
+		return ok, err
+	}
+	return false, ErrUnsupportedHash
+}
+
+func (h *upgradingHasher) NeedsRehash(hash string) bool {
+	return h.current.NeedsRehash(hash)
+}
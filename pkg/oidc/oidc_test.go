@@ -0,0 +1,287 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"microservices/pkg/oidc/oidctest"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const testClientID = "test-client"
+
+func newTestProvider(t *testing.T) (*oidctest.Server, *Provider) {
+	t.Helper()
+
+	server, err := oidctest.NewServer(testClientID, oidctest.User{
+		Subject:       "subject-1",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Name:          "Alice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	provider := NewProvider(Config{
+		Issuer:      server.Issuer(),
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost/callback",
+	})
+	return server, provider
+}
+
+// authorize follows the authorization URL like a browser would and returns
+// the code and state the provider redirects back with.
+func authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: got status %s, want 302", resp.Status)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	server, provider := newTestProvider(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+		sign   func(claims jwt.MapClaims) (string, error)
+		nonce  string
+		valid  bool
+	}{
+		{
+			name:  "valid",
+			nonce: "nonce-1",
+			valid: true,
+		},
+		{
+			name:  "bad signature",
+			nonce: "nonce-1",
+			sign: func(claims jwt.MapClaims) (string, error) {
+				idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+				idToken.Header["kid"] = "oidctest"
+				return idToken.SignedString(otherKey)
+			},
+		},
+		{
+			name:  "unsigned",
+			nonce: "nonce-1",
+			sign: func(claims jwt.MapClaims) (string, error) {
+				return jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			},
+		},
+		{
+			name:   "wrong audience",
+			nonce:  "nonce-1",
+			modify: func(claims jwt.MapClaims) { claims["aud"] = "other-client" },
+		},
+		{
+			name:   "wrong issuer",
+			nonce:  "nonce-1",
+			modify: func(claims jwt.MapClaims) { claims["iss"] = "https://attacker.example.com" },
+		},
+		{
+			name:   "other authorized party",
+			nonce:  "nonce-1",
+			modify: func(claims jwt.MapClaims) { claims["azp"] = "other-client" },
+		},
+		{
+			name:   "expired",
+			nonce:  "nonce-1",
+			modify: func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+		},
+		{
+			name:   "missing expiry",
+			nonce:  "nonce-1",
+			modify: func(claims jwt.MapClaims) { delete(claims, "exp") },
+		},
+		{
+			name:  "nonce mismatch",
+			nonce: "nonce-2",
+		},
+		{
+			name:   "missing subject",
+			nonce:  "nonce-1",
+			modify: func(claims jwt.MapClaims) { delete(claims, "sub") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := server.IDTokenClaims("nonce-1")
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+
+			sign := server.SignIDToken
+			if tt.sign != nil {
+				sign = tt.sign
+			}
+
+			rawIDToken, err := sign(claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			idToken, err := provider.VerifyIDToken(context.Background(), rawIDToken, tt.nonce)
+
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Fatalf("got error %v, want %v", err, ErrInvalidIDToken)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idToken.Subject != "subject-1" || idToken.Email != "alice@example.com" || !idToken.EmailVerified || idToken.Name != "Alice" {
+				t.Errorf("got %+v", idToken)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenEmailVerifiedString(t *testing.T) {
+	server, provider := newTestProvider(t)
+
+	claims := server.IDTokenClaims("nonce-1")
+	claims["email_verified"] = "true"
+
+	rawIDToken, err := server.SignIDToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idToken, err := provider.VerifyIDToken(context.Background(), rawIDToken, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !idToken.EmailVerified {
+		t.Error("email_verified \"true\" was not accepted")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name         string
+		codeVerifier func(verifier string) string
+		reuseCode    bool
+		valid        bool
+	}{
+		{
+			name:         "matching code verifier",
+			codeVerifier: func(verifier string) string { return verifier },
+			valid:        true,
+		},
+		{
+			name:         "wrong code verifier",
+			codeVerifier: func(string) string { return "wrong-verifier" },
+		},
+		{
+			name:         "missing code verifier",
+			codeVerifier: func(string) string { return "" },
+		},
+		{
+			name:         "code used twice",
+			codeVerifier: func(verifier string) string { return verifier },
+			reuseCode:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, provider := newTestProvider(t)
+			ctx := context.Background()
+
+			verifier, err := NewCodeVerifier()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, state := authorize(t, authURL)
+			if state != "state-1" {
+				t.Fatalf("got state %q, want %q", state, "state-1")
+			}
+
+			if tt.reuseCode {
+				_, err = provider.Exchange(ctx, code, verifier)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rawIDToken, err := provider.Exchange(ctx, code, tt.codeVerifier(verifier))
+
+			if !tt.valid {
+				if !errors.Is(err, ErrExchange) {
+					t.Fatalf("got error %v, want %v", err, ErrExchange)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			idToken, err := provider.VerifyIDToken(ctx, rawIDToken, "nonce-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idToken.Subject != "subject-1" {
+				t.Errorf("got subject %q, want %q", idToken.Subject, "subject-1")
+			}
+		})
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	server, _ := newTestProvider(t)
+
+	provider := NewProvider(Config{
+		Issuer:   server.Issuer() + "/",
+		ClientID: testClientID,
+	})
+
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	if !errors.Is(err, ErrDiscovery) {
+		t.Fatalf("got error %v, want %v", err, ErrDiscovery)
+	}
+}
@@ -0,0 +1,97 @@
+// Package privacy carries data-subject requests (GDPR export and erasure)
+// from the user service to the services that hold personal data.
+package privacy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"microservices/pkg/token"
+	"net/http"
+	"time"
+)
+
+// Permission guards the export and erasure endpoints. It is not granted to
+// any role; the user service mints short-lived tokens carrying it while it
+// processes a request.
+const Permission = "privacy:process"
+
+// Subject names the person a request is about. Services pick whichever
+// field their records are keyed by.
+type Subject struct {
+	UserID int64  `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// ErasureResult reports how many records a service scrubbed.
+type ErasureResult struct {
+	Erased int64 `json:"erased"`
+}
+
+// Client calls the /v1/privacy endpoints of one service.
+type Client struct {
+	baseURL string
+	tokens  token.TokenManager
+	client  *http.Client
+}
+
+// NewClient returns a client for the service at baseURL, e.g.
+// http://localhost:4040. tokens must be able to sign.
+func NewClient(baseURL string, tokens token.TokenManager) *Client {
+	return &Client{baseURL: baseURL, tokens: tokens, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Export returns everything the service holds about subject as raw JSON.
+// actorID is the user the request is made on behalf of.
+func (c *Client) Export(ctx context.Context, actorID int64, subject Subject) (json.RawMessage, error) {
+	var data json.RawMessage
+
+	err := c.post(ctx, "/v1/privacy/export", actorID, subject, &data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Erase scrubs or pseudonymises the records the service holds about subject.
+func (c *Client) Erase(ctx context.Context, actorID int64, subject Subject) (int64, error) {
+	var result ErasureResult
+
+	err := c.post(ctx, "/v1/privacy/erase", actorID, subject, &result)
+	if err != nil {
+		return 0, err
+	}
+	return result.Erased, nil
+}
+
+func (c *Client) post(ctx context.Context, path string, actorID int64, subject Subject, dst any) error {
+	accessToken, err := c.tokens.NewToken(token.Identity{UserID: actorID, Permissions: []string{Permission}}, time.Minute)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(subject)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s%s: unexpected status %s", c.baseURL, path, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
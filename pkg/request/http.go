@@ -7,6 +7,7 @@ import (
 	"github.com/julienschmidt/httprouter"
 	"io"
 	"microservices/pkg/validator"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -110,6 +111,15 @@ func ServerErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	ErrorResponse(w, r, http.StatusInternalServerError, message)
 }
 
+// ServiceUnavailableResponse reports a failure of another service the
+// request depends on; the client may retry later.
+func ServiceUnavailableResponse(w http.ResponseWriter, r *http.Request, err error) {
+	w.Header().Set("Retry-After", "5")
+
+	message := "the server is temporarily unable to process your request, please try again later"
+	ErrorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
 func NotFoundResponse(w http.ResponseWriter, r *http.Request) {
 	message := "the requested resource could not be found"
 	ErrorResponse(w, r, http.StatusNotFound, message)
@@ -143,6 +153,47 @@ func RateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	ErrorResponse(w, r, http.StatusTooManyRequests, message)
 }
 
+func InvalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+
+	message := "invalid or missing authentication token"
+	ErrorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func AuthenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you must be authenticated to access this resource"
+	ErrorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func UnverifiedAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your email address must be verified before you can sign in"
+	ErrorResponse(w, r, http.StatusForbidden, message)
+}
+
+func AccountDisabledResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account has been disabled"
+	ErrorResponse(w, r, http.StatusForbidden, message)
+}
+
+func PasswordResetRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you must reset your password before you can sign in, check your email for instructions"
+	ErrorResponse(w, r, http.StatusForbidden, message)
+}
+
+func NotPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	ErrorResponse(w, r, http.StatusForbidden, message)
+}
+
+// ClientIP returns the IP address of the peer that sent the request.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func ReadEmailParam(r *http.Request) (string, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 	return params.ByName("email"), nil
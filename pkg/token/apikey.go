@@ -0,0 +1,211 @@
+package token
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"microservices/pkg/request"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKeyPrefix starts every API key so that it can be told apart from a JWT
+// in an Authorization header.
+const APIKeyPrefix = "mk_"
+
+// APIKeyResolver exchanges an API key for the identity it acts as.
+type APIKeyResolver interface {
+	ResolveAPIKey(ctx context.Context, key string) (Identity, error)
+}
+
+// NewAPIKey returns a key of the form mk_<prefix>_<secret>, its public
+// prefix used to look the key up, and the hash to persist.
+func NewAPIKey() (key, prefix string, hash []byte, err error) {
+	prefixBytes := make([]byte, 4)
+
+	_, err = rand.Read(prefixBytes)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	secret, _, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	prefix = APIKeyPrefix + hex.EncodeToString(prefixBytes)
+	key = prefix + "_" + strings.ToLower(secret)
+	return key, prefix, HashOpaqueToken(key), nil
+}
+
+// ParseAPIKey returns the lookup prefix of key.
+func ParseAPIKey(key string) (string, bool) {
+	if !IsAPIKey(key) {
+		return "", false
+	}
+
+	prefix, secret, ok := strings.Cut(key[len(APIKeyPrefix):], "_")
+	if !ok || len(prefix) != 8 || secret == "" {
+		return "", false
+	}
+	return APIKeyPrefix + prefix, true
+}
+
+func IsAPIKey(s string) bool {
+	return strings.HasPrefix(s, APIKeyPrefix)
+}
+
+// ServiceKeyHeader carries the shared key services present to each other.
+// It is separate from Authorization, which names the user acting.
+const ServiceKeyHeader = "X-Service-Key"
+
+// RequireServiceKey only lets through requests that carry serviceKey in
+// ServiceKeyHeader. It guards endpoints meant for other services, such as
+// API key introspection; with an empty serviceKey every request is refused.
+func RequireServiceKey(serviceKey string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(ServiceKeyHeader)
+		if serviceKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(serviceKey)) != 1 {
+			request.NotPermittedResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+const maxCachedAPIKeys = 1024
+
+type apiKeyState struct {
+	identity Identity
+	valid    bool
+	expires  time.Time
+}
+
+type remoteAPIKeyResolver struct {
+	url        string
+	serviceKey string
+	client     *http.Client
+	cacheTTL   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]apiKeyState
+}
+
+// NewRemoteAPIKeyResolver resolves API keys through the introspection
+// endpoint of the user service, e.g.
+// http://localhost:4000/v1/api-keys/introspect, authenticating with the
+// service key the user service was configured with. Answers, including
+// rejections, are cached for cacheTTL, which bounds how long a revoked key
+// keeps working in the calling service and keeps each request from costing
+// an introspection call.
+func NewRemoteAPIKeyResolver(url, serviceKey string, cacheTTL time.Duration) *remoteAPIKeyResolver {
+	return &remoteAPIKeyResolver{
+		url:        url,
+		serviceKey: serviceKey,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		cacheTTL:   cacheTTL,
+		cache:      make(map[string]apiKeyState),
+	}
+}
+
+// APIKeyIntrospection is the body exchanged with the introspection endpoint.
+type APIKeyIntrospection struct {
+	APIKey      string   `json:"api_key,omitempty"`
+	UserID      int64    `json:"user_id,omitempty"`
+	Email       string   `json:"email,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	OrgID       int64    `json:"org_id,omitempty"`
+	OrgRole     string   `json:"org_role,omitempty"`
+}
+
+func (r *remoteAPIKeyResolver) ResolveAPIKey(ctx context.Context, key string) (Identity, error) {
+	now := time.Now()
+	// Keys are cached by their hash so that the cache holds no secrets.
+	cacheKey := string(HashOpaqueToken(key))
+
+	r.mu.Lock()
+	state, ok := r.cache[cacheKey]
+	r.mu.Unlock()
+
+	if !ok || !now.Before(state.expires) {
+		identity, valid, err := r.introspect(ctx, key)
+		if err != nil {
+			// While the user service cannot be reached, the last known state
+			// is trusted for one more cache period instead of failing every
+			// request.
+			if !ok || !now.Before(state.expires.Add(r.cacheTTL)) {
+				return Identity{}, err
+			}
+		} else {
+			state = apiKeyState{identity: identity, valid: valid, expires: now.Add(r.cacheTTL)}
+
+			r.mu.Lock()
+			if len(r.cache) >= maxCachedAPIKeys {
+				for k, cached := range r.cache {
+					if now.After(cached.expires) {
+						delete(r.cache, k)
+					}
+				}
+			}
+			r.cache[cacheKey] = state
+			r.mu.Unlock()
+		}
+	}
+
+	if !state.valid {
+		return Identity{}, ErrInvalidToken
+	}
+	return state.identity, nil
+}
+
+// introspect asks the user service about key. A key it rejects is reported
+// as not valid rather than as an error, so that the answer can be cached.
+func (r *remoteAPIKeyResolver) introspect(ctx context.Context, key string) (Identity, bool, error) {
+	body, err := json.Marshal(APIKeyIntrospection{APIKey: key})
+	if err != nil {
+		return Identity{}, false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return Identity{}, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ServiceKeyHeader, r.serviceKey)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return Identity{}, false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return Identity{}, false, nil
+	default:
+		return Identity{}, false, fmt.Errorf("%w: introspect api key: unexpected status %s", ErrUnavailable, resp.Status)
+	}
+
+	var result APIKeyIntrospection
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return Identity{}, false, fmt.Errorf("%w: introspect api key: %v", ErrUnavailable, err)
+	}
+
+	return Identity{
+		UserID:      result.UserID,
+		Email:       result.Email,
+		Roles:       result.Roles,
+		Permissions: result.Permissions,
+		OrgID:       result.OrgID,
+		OrgRole:     result.OrgRole,
+	}, true, nil
+}
@@ -0,0 +1,172 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"microservices/pkg/request"
+	"net/http"
+	"strings"
+)
+
+type contextKey string
+
+const identityContextKey = contextKey("identity")
+
+func ContextWithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey, identity)
+}
+
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityContextKey).(Identity)
+	return identity, ok
+}
+
+func UserIDFromContext(ctx context.Context) (int64, bool) {
+	identity, ok := IdentityFromContext(ctx)
+	return identity.UserID, ok
+}
+
+// OrgIDFromContext returns the organization the request acts in; ok is
+// false when there is none.
+func OrgIDFromContext(ctx context.Context) (int64, bool) {
+	identity, _ := IdentityFromContext(ctx)
+	return identity.OrgID, identity.OrgID != 0
+}
+
+type Middleware struct {
+	tokens   TokenManager
+	apiKeys  APIKeyResolver
+	sessions SessionChecker
+}
+
+func NewMiddleware(tokens TokenManager) *Middleware {
+	return &Middleware{tokens: tokens}
+}
+
+// WithSessionCheck makes Authenticate reject access tokens whose session has
+// been revoked.
+func (m *Middleware) WithSessionCheck(checker SessionChecker) *Middleware {
+	m.sessions = checker
+	return m
+}
+
+// WithAPIKeys makes Authenticate accept API keys as bearer tokens too.
+func (m *Middleware) WithAPIKeys(resolver APIKeyResolver) *Middleware {
+	m.apiKeys = resolver
+	return m
+}
+
+// Authenticate puts the identity of a valid bearer token into the request
+// context. Requests without an Authorization header pass through anonymously.
+func (m *Middleware) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			request.InvalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		identity, err := m.Identify(r.Context(), headerParts[1])
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrExpiredToken):
+				request.InvalidAuthenticationTokenResponse(w, r)
+			case errors.Is(err, ErrUnavailable):
+				request.ServiceUnavailableResponse(w, r, err)
+			default:
+				request.ServerErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ContextWithIdentity(r.Context(), identity)))
+	})
+}
+
+// Identify returns the identity behind a bearer token, an access token or an
+// API key. Authenticate uses it for HTTP; other transports can call it
+// directly.
+func (m *Middleware) Identify(ctx context.Context, bearer string) (Identity, error) {
+	if IsAPIKey(bearer) {
+		if m.apiKeys == nil {
+			return Identity{}, ErrInvalidToken
+		}
+		return m.apiKeys.ResolveAPIKey(ctx, bearer)
+	}
+
+	claims, err := m.tokens.Parse(bearer)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if m.sessions != nil && claims.SessionID != 0 {
+		active, err := m.sessions.SessionActive(ctx, bearer, claims.Identity)
+		if err != nil {
+			return Identity{}, err
+		}
+		if !active {
+			return Identity{}, ErrInvalidToken
+		}
+	}
+	return claims.Identity, nil
+}
+
+// RequireAuth rejects requests that Authenticate did not attach a user to.
+func (m *Middleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := IdentityFromContext(r.Context()); !ok {
+			request.AuthenticationRequiredResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RequirePermission rejects authenticated users whose token does not carry
+// the given permission code, e.g. "contracts:write".
+func (m *Middleware) RequirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := IdentityFromContext(r.Context())
+		if !identity.HasPermission(code) {
+			request.NotPermittedResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return m.RequireAuth(fn)
+}
+
+// RequireOrganization rejects authenticated users whose token is not bound
+// to an organization, so that tenant data cannot be reached without one.
+func (m *Middleware) RequireOrganization(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := OrgIDFromContext(r.Context()); !ok {
+			request.NotPermittedResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return m.RequireAuth(fn)
+}
+
+// RequireOrgAdmin is RequireOrganization for owners and admins of the
+// organization only.
+func (m *Middleware) RequireOrgAdmin(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := IdentityFromContext(r.Context())
+		if identity.OrgRole != "owner" && identity.OrgRole != "admin" {
+			request.NotPermittedResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return m.RequireOrganization(fn)
+}
@@ -0,0 +1,102 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SessionChecker reports whether the session an access token was issued
+// for is still active.
+type SessionChecker interface {
+	SessionActive(ctx context.Context, accessToken string, identity Identity) (bool, error)
+}
+
+const maxCachedSessions = 1024
+
+type sessionState struct {
+	active  bool
+	expires time.Time
+}
+
+type remoteSessionChecker struct {
+	url      string
+	client   *http.Client
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[int64]sessionState
+}
+
+// NewRemoteSessionChecker asks the user service whether a session is still
+// active, e.g. at http://localhost:4000/v1/user/sessions/current. Answers
+// are cached for cacheTTL, which bounds how long a revoked session keeps
+// working in the calling service.
+func NewRemoteSessionChecker(url string, cacheTTL time.Duration) *remoteSessionChecker {
+	return &remoteSessionChecker{
+		url:      url,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		cacheTTL: cacheTTL,
+		cache:    make(map[int64]sessionState),
+	}
+}
+
+func (c *remoteSessionChecker) SessionActive(ctx context.Context, accessToken string, identity Identity) (bool, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	state, ok := c.cache[identity.SessionID]
+	c.mu.Unlock()
+
+	if ok && now.Before(state.expires) {
+		return state.active, nil
+	}
+
+	active, err := c.fetch(ctx, accessToken)
+	if err != nil {
+		// While the user service cannot be reached, the last known state is
+		// trusted for one more cache period instead of failing every request.
+		if ok && now.Before(state.expires.Add(c.cacheTTL)) {
+			return state.active, nil
+		}
+		return false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	c.mu.Lock()
+	if len(c.cache) >= maxCachedSessions {
+		for id, state := range c.cache {
+			if now.After(state.expires) {
+				delete(c.cache, id)
+			}
+		}
+	}
+	c.cache[identity.SessionID] = sessionState{active: active, expires: now.Add(c.cacheTTL)}
+	c.mu.Unlock()
+
+	return active, nil
+}
+
+func (c *remoteSessionChecker) fetch(ctx context.Context, accessToken string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return true, nil
+	case http.StatusUnauthorized:
+		return false, nil
+	default:
+		return false, fmt.Errorf("check session: unexpected status %s", resp.Status)
+	}
+}
@@ -2,31 +2,211 @@ package token
 
 import (
 	"errors"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 )
 
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token has expired")
+	ErrNoSigningKey = errors.New("token manager has no signing key")
+	// ErrUnavailable means the service that vouches for a token could not be
+	// asked; the token is neither accepted nor known to be invalid.
+	ErrUnavailable = errors.New("token cannot be checked right now")
+)
+
 type TokenManager interface {
-	NewToken(userId int64, ttl time.Duration) (string, error)
+	NewToken(identity Identity, ttl time.Duration) (string, error)
+	Parse(accessToken string) (*Claims, error)
+	JWKS() JSONWebKeySet
+}
+
+// Identity is what a token says about its bearer. The user ID travels in
+// the standard subject claim.
+type Identity struct {
+	UserID      int64    `json:"-"`
+	Email       string   `json:"email,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	// SessionID names the sign-in the token was issued for, so that the
+	// token can be rejected once that session is revoked.
+	SessionID int64 `json:"sid,omitempty"`
+	// OrgID is the organization the bearer is acting in and OrgRole their
+	// role there. Tenant data is only visible within that organization.
+	OrgID   int64  `json:"org_id,omitempty"`
+	OrgRole string `json:"org_role,omitempty"`
+}
+
+func (i Identity) HasPermission(code string) bool {
+	for _, permission := range i.Permissions {
+		if permission == code {
+			return true
+		}
+	}
+	return false
+}
+
+type Claims struct {
+	jwt.StandardClaims
+	Identity
+}
+
+type Config struct {
+	Issuer   string
+	Audience string
+
+	// HMACSecret is only used when no asymmetric keys are configured.
+	HMACSecret string
+
+	SigningKeyID   string
+	SigningKeyFile string
+
+	// VerificationKeys is a comma-separated list of kid=path public keys,
+	// e.g. the previous signing key while it is being rotated out.
+	VerificationKeys string
 }
 
 type Manager struct {
-	signingKey string
+	signingKey *Key
+	keys       map[string]*Key
+	issuer     string
+	audience   string
 }
 
-func NewManager(signingKey string) (*Manager, error) {
+func NewManager(signingKey, issuer, audience string) (*Manager, error) {
 	if signingKey == "" {
 		return nil, errors.New("empty signing key")
 	}
-	return &Manager{signingKey: signingKey}, nil
+	return NewKeyManager(NewHMACKey("", signingKey), nil, issuer, audience)
+}
+
+// NewKeyManager builds a manager that signs with signingKey and accepts
+// tokens signed by it or any of verificationKeys. signingKey may be nil for
+// services that only verify tokens.
+func NewKeyManager(signingKey *Key, verificationKeys []*Key, issuer, audience string) (*Manager, error) {
+	m := &Manager{
+		signingKey: signingKey,
+		keys:       make(map[string]*Key),
+		issuer:     issuer,
+		audience:   audience,
+	}
+
+	if signingKey != nil {
+		m.keys[signingKey.ID] = signingKey
+	}
+
+	for _, key := range verificationKeys {
+		if _, exists := m.keys[key.ID]; exists {
+			return nil, errors.New("duplicate key id: " + key.ID)
+		}
+		m.keys[key.ID] = key
+	}
+
+	if len(m.keys) == 0 {
+		return nil, errors.New("no signing or verification keys")
+	}
+	return m, nil
+}
+
+func NewManagerFromConfig(cfg Config) (*Manager, error) {
+	if cfg.SigningKeyFile == "" && cfg.VerificationKeys == "" {
+		return NewManager(cfg.HMACSecret, cfg.Issuer, cfg.Audience)
+	}
+
+	var signingKey *Key
+	if cfg.SigningKeyFile != "" {
+		key, err := LoadSigningKey(cfg.SigningKeyID, cfg.SigningKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signingKey = key
+	}
+
+	verificationKeys, err := LoadVerificationKeys(cfg.VerificationKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeyManager(signingKey, verificationKeys, cfg.Issuer, cfg.Audience)
 }
 
-func (m *Manager) NewToken(userId int64, ttl time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(ttl).Unix(),
-		Subject:   strconv.FormatInt(userId, 10),
+func (m *Manager) NewToken(identity Identity, ttl time.Duration) (string, error) {
+	if m.signingKey == nil {
+		return "", ErrNoSigningKey
+	}
+
+	now := time.Now()
+
+	token := jwt.NewWithClaims(m.signingKey.Method, Claims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  m.audience,
+			ExpiresAt: now.Add(ttl).Unix(),
+			IssuedAt:  now.Unix(),
+			Issuer:    m.issuer,
+			Subject:   strconv.FormatInt(identity.UserID, 10),
+		},
+		Identity: identity,
 	})
-	return token.SignedString([]byte(m.signingKey))
+	if m.signingKey.ID != "" {
+		token.Header["kid"] = m.signingKey.ID
+	}
+	return token.SignedString(m.signingKey.signKey)
+}
+
+func (m *Manager) Parse(accessToken string) (*Claims, error) {
+	var claims Claims
+
+	_, err := jwt.ParseWithClaims(accessToken, &claims, m.keyFunc)
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		switch {
+		case errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0:
+			return nil, ErrExpiredToken
+		default:
+			return nil, ErrInvalidToken
+		}
+	}
+
+	if !claims.VerifyIssuer(m.issuer, true) || !claims.VerifyAudience(m.audience, true) {
+		return nil, ErrInvalidToken
+	}
+
+	claims.UserID, err = strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || claims.UserID < 1 {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
+
+// JWKS returns the public halves of all asymmetric keys known to the manager.
+func (m *Manager) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range m.keys {
+		if jwk, ok := key.jwk(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+func (m *Manager) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown key id: " + kid)
+	}
+
+	// The algorithm is pinned to the key so a token cannot, for example,
+	// present an RSA public key as an HMAC secret.
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method: " + token.Method.Alg())
+	}
+	return key.verifyKey, nil
 }
@@ -0,0 +1,62 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the length of a SecretBox key: AES-256.
+const KeySize = 32
+
+var ErrUnreadableSecret = errors.New("totp: secret cannot be decrypted")
+
+// SecretBox encrypts TOTP secrets for storage with AES-256-GCM. Unlike
+// passwords they cannot be hashed, as codes are computed from them.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("totp: secret key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal encrypts the secret. additionalData, typically the owner's ID, must
+// be passed to Open again, so a sealed secret cannot be moved to another
+// account.
+func (b *SecretBox) Seal(secret string, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize(), b.aead.NonceSize()+len(secret)+b.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, []byte(secret), additionalData), nil
+}
+
+func (b *SecretBox) Open(sealed, additionalData []byte) (string, error) {
+	if len(sealed) < b.aead.NonceSize() {
+		return "", ErrUnreadableSecret
+	}
+
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+
+	secret, err := b.aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return "", ErrUnreadableSecret
+	}
+	return string(secret), nil
+}
@@ -0,0 +1,73 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCodeRFC6238 checks the SHA-1 vectors of RFC 6238 Appendix B. The RFC
+// lists eight digits; six-digit codes are their last six.
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		step int64
+		code string
+	}{
+		{unix: 59, step: 0x1, code: "287082"},
+		{unix: 1111111109, step: 0x23523EC, code: "081804"},
+		{unix: 1111111111, step: 0x23523ED, code: "050471"},
+		{unix: 1234567890, step: 0x273EF07, code: "005924"},
+		{unix: 2000000000, step: 0x3F940AA, code: "279037"},
+		{unix: 20000000000, step: 0x27BC86AA, code: "353130"},
+	}
+
+	for _, tt := range tests {
+		step := Step(time.Unix(tt.unix, 0))
+		if step != tt.step {
+			t.Errorf("T=%d: got step %#x, want %#x", tt.unix, step, tt.step)
+		}
+
+		code, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("T=%d: got code %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		valid    bool
+	}{
+		{name: "current step", secret: rfcSecret, code: "081804", wantStep: 0x23523EC, valid: true},
+		{name: "lower case secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: "081804", wantStep: 0x23523EC, valid: true},
+		{name: "next step within skew", secret: rfcSecret, code: "050471", wantStep: 0x23523ED, valid: true},
+		{name: "far away step", secret: rfcSecret, code: "005924"},
+		{name: "wrong length", secret: rfcSecret, code: "94287082"},
+		{name: "invalid secret", secret: "not base32!", code: "081804"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(tt.secret, tt.code, now)
+			if ok != tt.valid {
+				t.Fatalf("got valid %v, want %v", ok, tt.valid)
+			}
+			if ok && step != tt.wantStep {
+				t.Errorf("got step %#x, want %#x", step, tt.wantStep)
+			}
+		})
+	}
+}
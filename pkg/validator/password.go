@@ -0,0 +1,194 @@
+package validator
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PasswordPolicy decides whether a password may be chosen. userInputs are
+// things the user has told us about themselves, such as their name and email
+// address. Problems are added to v under the "password" key; the error is
+// only for failures such as an unreadable breach corpus.
+type PasswordPolicy interface {
+	ValidatePassword(v *Validator, password string, userInputs ...string) error
+}
+
+// PasswordRule returns why password is unacceptable, or "" if it passes.
+type PasswordRule func(password string, userInputs []string) (string, error)
+
+type passwordPolicy struct {
+	rules []PasswordRule
+}
+
+// NewPasswordPolicy builds a policy that applies rules in order and reports
+// the first problem found.
+func NewPasswordPolicy(rules ...PasswordRule) *passwordPolicy {
+	return &passwordPolicy{rules: rules}
+}
+
+func (p *passwordPolicy) ValidatePassword(v *Validator, password string, userInputs ...string) error {
+	for _, rule := range p.rules {
+		problem, err := rule(password, userInputs)
+		if err != nil {
+			return err
+		}
+		if problem != "" {
+			v.AddError("password", problem)
+			return nil
+		}
+	}
+	return nil
+}
+
+// NoUserInputs rejects passwords that contain the user's name, any part of
+// it, or their email address or its local part.
+func NoUserInputs() PasswordRule {
+	return func(password string, userInputs []string) (string, error) {
+		lower := strings.ToLower(password)
+		for _, fragment := range userInputFragments(userInputs) {
+			if strings.Contains(lower, fragment) {
+				return "must not contain your name or email address", nil
+			}
+		}
+		return "", nil
+	}
+}
+
+// MinStrength rejects passwords whose PasswordStrength is below score.
+func MinStrength(score int) PasswordRule {
+	return func(password string, userInputs []string) (string, error) {
+		if PasswordStrength(password, userInputs...) < score {
+			return "is too easy to guess", nil
+		}
+		return "", nil
+	}
+}
+
+// NotBreached rejects passwords found in corpus.
+func NotBreached(corpus *BreachedPasswords) PasswordRule {
+	return func(password string, userInputs []string) (string, error) {
+		breached, err := corpus.Contains(password)
+		if err != nil {
+			return "", err
+		}
+		if breached {
+			return "has appeared in a data breach and must not be used", nil
+		}
+		return "", nil
+	}
+}
+
+// BreachedPasswords looks passwords up in a local copy of a breached
+// password corpus split by k-anonymity prefix: the directory holds one file
+// per first five hex digits of the SHA-1 hash, named after them (optionally
+// with a .txt extension), listing the remaining 35 digits as SUFFIX:COUNT
+// lines. This is the layout served by the Pwned Passwords range API.
+type BreachedPasswords struct {
+	dir      string
+	minCount int
+}
+
+// NewBreachedPasswords reads prefix files from dir. Hashes seen fewer than
+// minCount times are ignored.
+func NewBreachedPasswords(dir string, minCount int) (*BreachedPasswords, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New(dir + ": not a directory")
+	}
+	if minCount < 1 {
+		minCount = 1
+	}
+	return &BreachedPasswords{dir: dir, minCount: minCount}, nil
+}
+
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	file, err := b.openPrefix(prefix)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hashSuffix, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !strings.EqualFold(hashSuffix, suffix) {
+			continue
+		}
+		return breachCount(count) >= b.minCount, nil
+	}
+	return false, scanner.Err()
+}
+
+func (b *BreachedPasswords) openPrefix(prefix string) (*os.File, error) {
+	var err error
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		var file *os.File
+		file, err = os.Open(filepath.Join(b.dir, name))
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// breachCount parses the count of a corpus line; lines without one count
+// as a single sighting.
+func breachCount(s string) int {
+	count := 0
+	for _, r := range strings.TrimSpace(s) {
+		if r < '0' || r > '9' {
+			break
+		}
+		count = count*10 + int(r-'0')
+	}
+	if count == 0 {
+		return 1
+	}
+	return count
+}
+
+func userInputFragments(userInputs []string) []string {
+	var fragments []string
+
+	add := func(s string) {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if len(s) >= 3 {
+			fragments = append(fragments, s)
+		}
+	}
+
+	for _, input := range userInputs {
+		add(input)
+		if local, _, found := strings.Cut(input, "@"); found {
+			add(local)
+			input = local
+		}
+		for _, part := range strings.FieldsFunc(input, isSeparator) {
+			add(part)
+		}
+	}
+	return fragments
+}
+
+func isSeparator(r rune) bool {
+	return strings.ContainsRune(" .-_+'", r)
+}
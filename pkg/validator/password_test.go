@@ -0,0 +1,177 @@
+package validator
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hashParts splits the SHA-1 of password into the file name prefix and the
+// suffix listed in the file, as in the Pwned Passwords range API.
+func hashParts(password string) (prefix, suffix string) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return digest[:5], digest[5:]
+}
+
+func TestBreachedPasswordsContains(t *testing.T) {
+	prefix, suffix := hashParts("password")
+	_, otherSuffix := hashParts("letmein")
+
+	tests := []struct {
+		name     string
+		file     string
+		lines    []string
+		minCount int
+		want     bool
+	}{
+		{
+			name:     "listed",
+			file:     prefix,
+			lines:    []string{otherSuffix + ":2", suffix + ":3861493"},
+			minCount: 1,
+			want:     true,
+		},
+		{
+			name:     "txt extension",
+			file:     prefix + ".txt",
+			lines:    []string{suffix + ":10"},
+			minCount: 1,
+			want:     true,
+		},
+		{
+			name:     "lower case file name",
+			file:     strings.ToLower(prefix),
+			lines:    []string{suffix + ":10"},
+			minCount: 1,
+			want:     true,
+		},
+		{
+			name:     "lower case suffix and CRLF",
+			file:     prefix,
+			lines:    []string{strings.ToLower(suffix) + ":10\r"},
+			minCount: 1,
+			want:     true,
+		},
+		{
+			name:     "seen fewer than minCount times",
+			file:     prefix,
+			lines:    []string{suffix + ":3"},
+			minCount: 5,
+			want:     false,
+		},
+		{
+			name:     "seen exactly minCount times",
+			file:     prefix,
+			lines:    []string{suffix + ":5"},
+			minCount: 5,
+			want:     true,
+		},
+		{
+			name:     "line without count",
+			file:     prefix,
+			lines:    []string{suffix},
+			minCount: 1,
+			want:     true,
+		},
+		{
+			name:     "not in prefix file",
+			file:     prefix,
+			lines:    []string{otherSuffix + ":100"},
+			minCount: 1,
+			want:     false,
+		},
+		{
+			name:     "no prefix file",
+			file:     "00000",
+			lines:    []string{suffix + ":100"},
+			minCount: 1,
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			err := os.WriteFile(filepath.Join(dir, tt.file), []byte(strings.Join(tt.lines, "\n")+"\n"), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			corpus, err := NewBreachedPasswords(dir, tt.minCount)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := corpus.Contains("password")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Contains = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBreachedPasswordsRequiresDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "corpus.txt")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{file, filepath.Join(dir, "missing")} {
+		if _, err := NewBreachedPasswords(path, 1); err == nil {
+			t.Errorf("NewBreachedPasswords(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	prefix, suffix := hashParts("Tr0ub4dor&3")
+	if err := os.WriteFile(filepath.Join(dir, prefix), []byte(suffix+":42\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	corpus, err := NewBreachedPasswords(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := NewPasswordPolicy(NoUserInputs(), MinStrength(3), NotBreached(corpus))
+	userInputs := []string{"Alice Smith", "alice.smith@example.com"}
+
+	tests := []struct {
+		name     string
+		password string
+		want     string
+	}{
+		{name: "accepted", password: "xK9#mP2$vL", want: ""},
+		{name: "contains name", password: "xK9#smith$vL", want: "must not contain your name or email address"},
+		{name: "contains email local part", password: "alice.smith-xK9#", want: "must not contain your name or email address"},
+		{name: "too weak", password: "Dragon1990", want: "is too easy to guess"},
+		{name: "breached", password: "Tr0ub4dor&3", want: "has appeared in a data breach and must not be used"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+
+			err := policy.ValidatePassword(v, tt.password, userInputs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := v.Errors["password"]; got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
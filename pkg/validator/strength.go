@@ -0,0 +1,219 @@
+package validator
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// PasswordStrength scores password from 0 (trivially guessable) to 4 (very
+// hard to guess), in the spirit of zxcvbn: the password is split into the
+// cheapest sequence of recognisable patterns (common passwords, the user's
+// own details, repeats, sequences, keyboard runs and years) and plain
+// characters, and the score follows the estimated number of guesses.
+func PasswordStrength(password string, userInputs ...string) int {
+	guesses := estimateGuesses(password, userInputFragments(userInputs))
+
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// estimateGuesses returns log10 of the guesses needed for password. best[i]
+// is the cheapest way to cover the first i characters.
+func estimateGuesses(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	bruteforce := math.Log10(float64(charsetSize(runes)))
+
+	best := make([]float64, len(runes)+1)
+	for i := 1; i <= len(runes); i++ {
+		best[i] = math.Inf(1)
+	}
+
+	for i := 0; i < len(runes); i++ {
+		if cost := best[i] + bruteforce; cost < best[i+1] {
+			best[i+1] = cost
+		}
+		for _, m := range matchPatterns(lower, i, userInputs) {
+			cost := best[i] + m.guesses + caseVariations(runes[i:i+m.length])
+			if cost < best[i+m.length] {
+				best[i+m.length] = cost
+			}
+		}
+	}
+	return best[len(runes)]
+}
+
+type patternMatch struct {
+	length  int
+	guesses float64 // log10
+}
+
+func matchPatterns(lower []rune, start int, userInputs []string) []patternMatch {
+	var matches []patternMatch
+	rest := string(lower[start:])
+
+	for _, input := range userInputs {
+		if strings.HasPrefix(rest, input) {
+			matches = append(matches, patternMatch{length: len([]rune(input)), guesses: 1})
+		}
+	}
+
+	for rank, word := range commonPasswords {
+		if strings.HasPrefix(rest, word) {
+			matches = append(matches, patternMatch{length: len([]rune(word)), guesses: math.Log10(float64(rank + 2))})
+		}
+		if deleet := unleet(rest); deleet != rest && strings.HasPrefix(deleet, word) {
+			matches = append(matches, patternMatch{length: len([]rune(word)), guesses: math.Log10(float64(rank+2)) + 1})
+		}
+	}
+
+	if n := runLength(lower[start:], func(a, b rune) bool { return a == b }); n >= 3 {
+		matches = append(matches, patternMatch{length: n, guesses: math.Log10(float64(charsetSize(lower[start:start+1]) * n))})
+	}
+	if n := runLength(lower[start:], func(a, b rune) bool { return b-a == 1 || a-b == 1 }); n >= 3 {
+		matches = append(matches, patternMatch{length: n, guesses: math.Log10(float64(4 * n))})
+	}
+	if n := keyboardRunLength(lower[start:]); n >= 3 {
+		matches = append(matches, patternMatch{length: n, guesses: math.Log10(float64(40 * n))})
+	}
+	if len(rest) >= 4 && (strings.HasPrefix(rest, "19") || strings.HasPrefix(rest, "20")) && isDigits(rest[:4]) {
+		matches = append(matches, patternMatch{length: 4, guesses: math.Log10(200)})
+	}
+	return matches
+}
+
+// runLength returns how many runes from the start of s follow each other
+// according to step, keeping the same direction for sequences.
+func runLength(s []rune, step func(a, b rune) bool) int {
+	if len(s) == 0 {
+		return 0
+	}
+	n := 1
+	for n < len(s) && step(s[n-1], s[n]) {
+		if n >= 2 && s[n]-s[n-1] != s[n-1]-s[n-2] {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+var keyboardRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./", "1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik9ol0p"}
+
+func keyboardRunLength(s []rune) int {
+	longest := 0
+	for _, row := range keyboardRows {
+		for _, line := range []string{row, reverse(row)} {
+			n := 0
+			for n < len(s) && strings.Contains(line, string(s[:n+1])) {
+				n++
+			}
+			if n > longest {
+				longest = n
+			}
+		}
+	}
+	return longest
+}
+
+func caseVariations(runes []rune) float64 {
+	upper := 0
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	switch {
+	case upper == 0:
+		return 0
+	case upper == len(runes) || (upper == 1 && unicode.IsUpper(runes[0])):
+		return math.Log10(2)
+	default:
+		return math.Log10(float64(len(runes)))
+	}
+}
+
+func charsetSize(runes []rune) int {
+	var lower, upper, digit, other bool
+	for _, r := range runes {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if other {
+		size += 33
+	}
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
+
+var leetReplacer = strings.NewReplacer("4", "a", "@", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t")
+
+func unleet(s string) string {
+	return leetReplacer.Replace(s)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// commonPasswords are among the most used passwords and words found in
+// them, most common first.
+var commonPasswords = []string{
+	"password", "123456", "qwerty", "letmein", "welcome", "admin", "iloveyou",
+	"monkey", "dragon", "football", "baseball", "master", "sunshine", "princess",
+	"shadow", "superman", "michael", "login", "passw0rd", "trustno1", "starwars",
+	"hello", "freedom", "whatever", "secret", "charlie", "jordan", "jennifer",
+	"hunter", "batman", "summer", "winter", "spring", "autumn", "orange",
+	"ginger", "cheese", "pepper", "soccer", "hockey", "killer", "access",
+	"flower", "lovely", "family", "friend", "computer", "internet", "change",
+	"default", "user", "love", "pass", "test", "guest", "root", "qazwsx",
+	"zxcvbn", "asdfgh", "abc123", "111111", "000000", "654321", "666666",
+	"121212", "chocolate", "blink182", "mustang", "harley", "ranger", "thomas",
+	"robert", "daniel", "andrew", "matrix", "banana", "cookie", "purple",
+	"silver", "golden", "diamond", "forever", "angel", "pokemon", "minecraft",
+}
@@ -0,0 +1,45 @@
+package validator
+
+import "testing"
+
+func TestPasswordStrength(t *testing.T) {
+	userInputs := []string{"Alice Smith", "alice.smith@example.com"}
+
+	tests := []struct {
+		name       string
+		password   string
+		userInputs []string
+		want       int
+	}{
+		{name: "empty", password: "", want: 0},
+		{name: "common password", password: "password", want: 0},
+		{name: "capitalised common password", password: "Password1", want: 0},
+		{name: "leet common password", password: "P@ssw0rd", want: 0},
+		{name: "repeat", password: "aaaaaaaa", want: 0},
+		{name: "sequence", password: "abcdefgh", want: 0},
+		{name: "digit sequence", password: "1234567890", want: 0},
+		{name: "keyboard run", password: "qwertyuiop", want: 0},
+		{name: "column keyboard run", password: "1qaz2wsx", want: 0},
+		{name: "repeated word", password: "monkeymonkey", want: 0},
+		{name: "repeated year", password: "19871987", want: 1},
+		{name: "common word and year", password: "Dragon1990", want: 1},
+		{name: "common word, year and symbol", password: "summer2019!", want: 1},
+		{name: "short random", password: "Kz8q", want: 2},
+		{name: "words and digits", password: "purple77cat", want: 3},
+		{name: "random", password: "xK9#mP2$vL", want: 4},
+		{name: "passphrase", password: "correct horse battery staple", want: 4},
+		{name: "name and year without user inputs", password: "alice2023", want: 4},
+		{name: "name and year", password: "alice2023", userInputs: userInputs, want: 1},
+		{name: "email local part", password: "Alice.Smith!", userInputs: userInputs, want: 1},
+		{name: "user inputs do not weaken unrelated passwords", password: "xK9#mP2$vL", userInputs: userInputs, want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PasswordStrength(tt.password, tt.userInputs...)
+			if got != tt.want {
+				t.Errorf("PasswordStrength(%q) = %d, want %d", tt.password, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,24 +1,47 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"microservices/pkg/store/postgres"
+	"microservices/pkg/token"
+	"microservices/services/contract/internal/delivery/grpc"
 	"microservices/services/contract/internal/delivery/http"
 	"microservices/services/contract/internal/repository"
 	"microservices/services/contract/internal/usecase"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
 	dbConnCfg := postgres.ConnConfig{}
 	httpServerCfg := http.ServerConfig{}
+	grpcServerCfg := grpc.ServerConfig{}
+
+	tokenCfg := token.Config{HMACSecret: os.Getenv("TOKEN_KEY")}
+
+	contractCfg := usecase.Config{}
+
+	var (
+		userServiceURL  string
+		sessionCacheTTL time.Duration
+		apiKeyCacheTTL  time.Duration
+	)
 
 	flag.IntVar(&httpServerCfg.Port, "http-port", 4040, "HTTP server port")
 	flag.StringVar(&httpServerCfg.ReadTimeout, "http-read-timeout", "10s", "HTTP read timeout")
 	flag.StringVar(&httpServerCfg.WriteTimeout, "http-write-timeout", "30s", "HTTP write timeout")
 	flag.StringVar(&httpServerCfg.IdleTimeout, "http-idle-timeout", "1m", "HTTP idle timeout")
 
+	flag.IntVar(&grpcServerCfg.Port, "grpc-port", 5040, "gRPC server port")
+
+	flag.DurationVar(&contractCfg.DeletedRetention, "deleted-retention", 30*24*time.Hour, "How long deleted contracts can be restored before they are purged")
+	flag.DurationVar(&contractCfg.PurgeInterval, "purge-interval", time.Hour, "How often deleted contracts past their retention are purged; 0 disables purging")
+
 	flag.IntVar(&dbConnCfg.Port, "pg-port", 5432, "Postgres port")
 	flag.StringVar(&dbConnCfg.Host, "pg-host", "localhost", "Postgres host")
 	flag.StringVar(&dbConnCfg.User, "pg-user", os.Getenv("POSTGRE_USER"), "Postgres user")
@@ -26,6 +49,14 @@ func main() {
 	flag.StringVar(&dbConnCfg.DbName, "pg-db-name", os.Getenv("POSTGRE_DB_NAME"), "Postgres DB name")
 	flag.IntVar(&dbConnCfg.MaxOpenConns, "pg-max-open-conns", 15, "Postgres max open connections")
 	flag.StringVar(&dbConnCfg.MaxIdleTime, "pg-max-idle-time", "15m", "Postgres max connection idle time")
+
+	flag.StringVar(&tokenCfg.Issuer, "token-issuer", "microservices/user", "JWT issuer")
+	flag.StringVar(&tokenCfg.Audience, "token-audience", "microservices", "JWT audience")
+	flag.StringVar(&tokenCfg.VerificationKeys, "token-verification-keys", "", "Comma-separated kid=path list of PEM public keys accepted for JWT verification")
+
+	flag.StringVar(&userServiceURL, "user-service-url", "http://localhost:4000", "Base URL of the user service, used to resolve API keys and check sessions; API keys are rejected when empty")
+	flag.DurationVar(&sessionCacheTTL, "session-cache-ttl", 30*time.Second, "How long the state of a session is cached before asking the user service again")
+	flag.DurationVar(&apiKeyCacheTTL, "api-key-cache-ttl", 30*time.Second, "How long a resolved or rejected API key is cached before asking the user service again")
 	flag.Parse()
 
 	db, err := postgres.OpenDB(dbConnCfg)
@@ -36,14 +67,47 @@ func main() {
 
 	log.Print("database connection pool established")
 
+	tokenManager, err := token.NewManagerFromConfig(tokenCfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	auth := token.NewMiddleware(tokenManager)
+	if userServiceURL != "" {
+		userServiceURL = strings.TrimSuffix(userServiceURL, "/")
+		auth.WithAPIKeys(token.NewRemoteAPIKeyResolver(userServiceURL+"/v1/api-keys/introspect", os.Getenv("INTROSPECTION_KEY"), apiKeyCacheTTL))
+		auth.WithSessionCheck(token.NewRemoteSessionChecker(userServiceURL+"/v1/user/sessions/current", sessionCacheTTL))
+	}
+
 	userRepository := repository.NewRepo(db.Pool)
-	userService := usecase.New(userRepository)
+	userService := usecase.New(userRepository, contractCfg)
+
+	workerCtx, stopWorkers := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopWorkers()
 
-	httpServer := http.NewHttpServer(http.NewRouter(userService).GetRoutes(), httpServerCfg)
+	purgeDone := make(chan struct{})
+	go func() {
+		userService.PurgeDeletedContracts(workerCtx)
+		close(purgeDone)
+	}()
+
+	grpcServer := grpc.NewGrpcServer(grpc.New(userService, grpc.Options{}), auth, grpcServerCfg)
+
+	go func() {
+		err := grpcServer.Serve()
+		if err != nil {
+			log.Fatalf("Failed to start gRPC server: %v", err)
+		}
+	}()
+
+	httpServer := http.NewHttpServer(http.NewRouter(userService, auth).GetRoutes(), httpServerCfg)
 
 	err = httpServer.Serve()
 	if err != nil {
 		log.Fatal("Failed to start HTTP server")
 	}
 
+	<-purgeDone
+	log.Print("stopped deleted contract purge")
+
 }
@@ -2,18 +2,201 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"log"
 
-	contact "microservices/services/contract/internal/delivery/grpc/interface"
+	"microservices/pkg/token"
+	contract "microservices/services/contract/internal/delivery/grpc/interface"
+	"microservices/services/contract/internal/domain"
+	"microservices/services/contract/internal/repository"
+	"microservices/services/contract/internal/usecase"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-func (d *Delivery) CreateGroup(ctx context.Context, request *contact.CreateContractRequest) (*contact.CreateContractResponse, error) {
-	panic("implement me")
+func (d *Delivery) CreateContract(ctx context.Context, request *contract.CreateContractRequest) (*contract.CreateContractResponse, error) {
+	identity, err := requireOrganization(ctx, "contracts:write")
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := d.ucContact.CreateContract(ctx, usecase.CreateContractDTO{
+		Title:     request.GetTitle(),
+		Desc:      request.GetDescription(),
+		CreatedBy: identity.UserID,
+		OrgID:     identity.OrgID,
+	})
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return &contract.CreateContractResponse{Contract: toProtoContract(c)}, nil
+}
+
+func (d *Delivery) GetContract(ctx context.Context, request *contract.GetContractRequest) (*contract.GetContractResponse, error) {
+	identity, err := requireOrganization(ctx, "contracts:read")
+	if err != nil {
+		return nil, err
+	}
+
+	if request.GetId() < 1 {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+
+	c, err := d.ucContact.GetContractByID(ctx, identity.OrgID, request.GetId())
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return &contract.GetContractResponse{Contract: toProtoContract(c)}, nil
+}
+
+func (d *Delivery) ListContracts(ctx context.Context, request *contract.ListContractsRequest) (*contract.ListContractsResponse, error) {
+	identity, err := requireOrganization(ctx, "contracts:read")
+	if err != nil {
+		return nil, err
+	}
+
+	filters := repository.Filters{
+		Page:         int(request.GetPage()),
+		PageSize:     int(request.GetPageSize()),
+		Sort:         request.GetSort(),
+		SortSafelist: []string{"id", "title", "status", "-id", "-title", "-status"},
+	}
+	if filters.Page == 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize == 0 {
+		filters.PageSize = 20
+	}
+	if filters.Sort == "" {
+		filters.Sort = "id"
+	}
+
+	contracts, err := d.ucContact.GetContracts(ctx, identity.OrgID, request.GetTitle(), request.GetStatus(), filters)
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return &contract.ListContractsResponse{Contracts: toProtoContracts(contracts)}, nil
+}
+
+func (d *Delivery) UpdateContract(ctx context.Context, request *contract.UpdateContractRequest) (*contract.UpdateContractResponse, error) {
+	identity, err := requireOrganization(ctx, "contracts:write")
+	if err != nil {
+		return nil, err
+	}
+
+	if request.GetId() < 1 {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+
+	input := usecase.UpdateContractDTO{
+		Title:     request.Title,
+		Desc:      request.Description,
+		UpdatedBy: identity.UserID,
+	}
+	if request.GetVersion() != 0 {
+		version := request.GetVersion()
+		input.Version = &version
+	}
+
+	c, err := d.ucContact.UpdateContract(ctx, identity.OrgID, request.GetId(), input)
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return &contract.UpdateContractResponse{Contract: toProtoContract(c)}, nil
+}
+
+func (d *Delivery) DeleteContract(ctx context.Context, request *contract.DeleteContractRequest) (*contract.DeleteContractResponse, error) {
+	identity, err := requireOrganization(ctx, "contracts:write")
+	if err != nil {
+		return nil, err
+	}
+
+	if request.GetId() < 1 {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+
+	err = d.ucContact.DeleteContract(ctx, identity.OrgID, request.GetId())
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return &contract.DeleteContractResponse{}, nil
+}
+
+func (d *Delivery) TransitionContract(ctx context.Context, request *contract.TransitionContractRequest) (*contract.TransitionContractResponse, error) {
+	identity, err := requireOrganization(ctx, "contracts:write")
+	if err != nil {
+		return nil, err
+	}
+
+	if request.GetId() < 1 {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+
+	input := usecase.TransitionContractDTO{
+		Status:  request.GetStatus(),
+		Reason:  request.GetReason(),
+		ActorID: identity.UserID,
+	}
+	if request.GetVersion() != 0 {
+		version := request.GetVersion()
+		input.Version = &version
+	}
+
+	c, err := d.ucContact.TransitionContract(ctx, identity.OrgID, request.GetId(), input)
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return &contract.TransitionContractResponse{Contract: toProtoContract(c)}, nil
+}
+
+// requireOrganization is the gRPC counterpart of RequirePermission and
+// RequireOrganization on the HTTP routes.
+func requireOrganization(ctx context.Context, permission string) (token.Identity, error) {
+	identity, ok := token.IdentityFromContext(ctx)
+	if !ok {
+		return token.Identity{}, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if !identity.HasPermission(permission) || identity.OrgID == 0 {
+		return token.Identity{}, status.Error(codes.PermissionDenied, "not permitted")
+	}
+	return identity, nil
+}
+
+func errorStatus(err error) error {
+	switch {
+	case errors.Is(err, usecase.ErrFailedValidation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, repository.ErrRecordNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, usecase.ErrEditConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, usecase.ErrInvalidTransition), errors.Is(err, usecase.ErrContractLocked):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, usecase.ErrDuplicate):
+		return status.Error(codes.AlreadyExists, err.Error())
+	default:
+		log.Print(err)
+		return status.Error(codes.Internal, "internal error")
+	}
 }
 
-func (d *Delivery) UpdateGroup(ctx context.Context, request *contact.UpdateContractRequest) (*contact.UpdateContractResponse, error) {
-	panic("implement me")
+func toProtoContract(c *domain.Contract) *contract.Contract {
+	return &contract.Contract{
+		Id:          c.ID,
+		Title:       c.Title,
+		Description: c.Desc,
+		CreatedAt:   timestamppb.New(c.CreatedAt),
+		Version:     c.Version,
+		Status:      c.Status,
+	}
 }
 
-func (d *Delivery) DeleteGroup(ctx context.Context, request *contact.DeleteContractRequest) (*contact.DeleteContractResponse, error) {
-	panic("implement me")
+func toProtoContracts(contracts []*domain.Contract) []*contract.Contract {
+	result := make([]*contract.Contract, 0, len(contracts))
+	for _, c := range contracts {
+		result = append(result, toProtoContract(c))
+	}
+	return result
 }
@@ -19,9 +19,12 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	ContractService_CreateContract_FullMethodName = "/contract.ContractService/CreateContract"
-	ContractService_UpdateContract_FullMethodName = "/contract.ContractService/UpdateContract"
-	ContractService_DeleteContract_FullMethodName = "/contract.ContractService/DeleteContract"
+	ContractService_CreateContract_FullMethodName     = "/contract.ContractService/CreateContract"
+	ContractService_GetContract_FullMethodName        = "/contract.ContractService/GetContract"
+	ContractService_ListContracts_FullMethodName      = "/contract.ContractService/ListContracts"
+	ContractService_UpdateContract_FullMethodName     = "/contract.ContractService/UpdateContract"
+	ContractService_DeleteContract_FullMethodName     = "/contract.ContractService/DeleteContract"
+	ContractService_TransitionContract_FullMethodName = "/contract.ContractService/TransitionContract"
 )
 
 // ContractServiceClient is the client API for ContractService service.
@@ -29,8 +32,11 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ContractServiceClient interface {
 	CreateContract(ctx context.Context, in *CreateContractRequest, opts ...grpc.CallOption) (*CreateContractResponse, error)
+	GetContract(ctx context.Context, in *GetContractRequest, opts ...grpc.CallOption) (*GetContractResponse, error)
+	ListContracts(ctx context.Context, in *ListContractsRequest, opts ...grpc.CallOption) (*ListContractsResponse, error)
 	UpdateContract(ctx context.Context, in *UpdateContractRequest, opts ...grpc.CallOption) (*UpdateContractResponse, error)
 	DeleteContract(ctx context.Context, in *DeleteContractRequest, opts ...grpc.CallOption) (*DeleteContractResponse, error)
+	TransitionContract(ctx context.Context, in *TransitionContractRequest, opts ...grpc.CallOption) (*TransitionContractResponse, error)
 }
 
 type contractServiceClient struct {
@@ -50,6 +56,24 @@ func (c *contractServiceClient) CreateContract(ctx context.Context, in *CreateCo
 	return out, nil
 }
 
+func (c *contractServiceClient) GetContract(ctx context.Context, in *GetContractRequest, opts ...grpc.CallOption) (*GetContractResponse, error) {
+	out := new(GetContractResponse)
+	err := c.cc.Invoke(ctx, ContractService_GetContract_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *contractServiceClient) ListContracts(ctx context.Context, in *ListContractsRequest, opts ...grpc.CallOption) (*ListContractsResponse, error) {
+	out := new(ListContractsResponse)
+	err := c.cc.Invoke(ctx, ContractService_ListContracts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *contractServiceClient) UpdateContract(ctx context.Context, in *UpdateContractRequest, opts ...grpc.CallOption) (*UpdateContractResponse, error) {
 	out := new(UpdateContractResponse)
 	err := c.cc.Invoke(ctx, ContractService_UpdateContract_FullMethodName, in, out, opts...)
@@ -68,13 +92,25 @@ func (c *contractServiceClient) DeleteContract(ctx context.Context, in *DeleteCo
 	return out, nil
 }
 
+func (c *contractServiceClient) TransitionContract(ctx context.Context, in *TransitionContractRequest, opts ...grpc.CallOption) (*TransitionContractResponse, error) {
+	out := new(TransitionContractResponse)
+	err := c.cc.Invoke(ctx, ContractService_TransitionContract_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ContractServiceServer is the server API for ContractService service.
 // All implementations must embed UnimplementedContractServiceServer
 // for forward compatibility
 type ContractServiceServer interface {
 	CreateContract(context.Context, *CreateContractRequest) (*CreateContractResponse, error)
+	GetContract(context.Context, *GetContractRequest) (*GetContractResponse, error)
+	ListContracts(context.Context, *ListContractsRequest) (*ListContractsResponse, error)
 	UpdateContract(context.Context, *UpdateContractRequest) (*UpdateContractResponse, error)
 	DeleteContract(context.Context, *DeleteContractRequest) (*DeleteContractResponse, error)
+	TransitionContract(context.Context, *TransitionContractRequest) (*TransitionContractResponse, error)
 	mustEmbedUnimplementedContractServiceServer()
 }
 
@@ -85,12 +121,21 @@ type UnimplementedContractServiceServer struct {
 func (UnimplementedContractServiceServer) CreateContract(context.Context, *CreateContractRequest) (*CreateContractResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateContract not implemented")
 }
+func (UnimplementedContractServiceServer) GetContract(context.Context, *GetContractRequest) (*GetContractResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetContract not implemented")
+}
+func (UnimplementedContractServiceServer) ListContracts(context.Context, *ListContractsRequest) (*ListContractsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListContracts not implemented")
+}
 func (UnimplementedContractServiceServer) UpdateContract(context.Context, *UpdateContractRequest) (*UpdateContractResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateContract not implemented")
 }
 func (UnimplementedContractServiceServer) DeleteContract(context.Context, *DeleteContractRequest) (*DeleteContractResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteContract not implemented")
 }
+func (UnimplementedContractServiceServer) TransitionContract(context.Context, *TransitionContractRequest) (*TransitionContractResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TransitionContract not implemented")
+}
 func (UnimplementedContractServiceServer) mustEmbedUnimplementedContractServiceServer() {}
 
 // UnsafeContractServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -122,6 +167,42 @@ func _ContractService_CreateContract_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ContractService_GetContract_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetContractRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContractServiceServer).GetContract(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContractService_GetContract_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContractServiceServer).GetContract(ctx, req.(*GetContractRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ContractService_ListContracts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListContractsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContractServiceServer).ListContracts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContractService_ListContracts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContractServiceServer).ListContracts(ctx, req.(*ListContractsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _ContractService_UpdateContract_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UpdateContractRequest)
 	if err := dec(in); err != nil {
@@ -158,6 +239,24 @@ func _ContractService_DeleteContract_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ContractService_TransitionContract_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransitionContractRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ContractServiceServer).TransitionContract(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ContractService_TransitionContract_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ContractServiceServer).TransitionContract(ctx, req.(*TransitionContractRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ContractService_ServiceDesc is the grpc.ServiceDesc for ContractService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -169,6 +268,14 @@ var ContractService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateContract",
 			Handler:    _ContractService_CreateContract_Handler,
 		},
+		{
+			MethodName: "GetContract",
+			Handler:    _ContractService_GetContract_Handler,
+		},
+		{
+			MethodName: "ListContracts",
+			Handler:    _ContractService_ListContracts_Handler,
+		},
 		{
 			MethodName: "UpdateContract",
 			Handler:    _ContractService_UpdateContract_Handler,
@@ -177,6 +284,10 @@ var ContractService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteContract",
 			Handler:    _ContractService_DeleteContract_Handler,
 		},
+		{
+			MethodName: "TransitionContract",
+			Handler:    _ContractService_TransitionContract_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "contract.proto",
@@ -0,0 +1,97 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"microservices/pkg/token"
+	contract "microservices/services/contract/internal/delivery/grpc/interface"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type grpcServer struct {
+	delivery *Delivery
+	auth     *token.Middleware
+	config   ServerConfig
+}
+
+type ServerConfig struct {
+	Port int
+}
+
+func NewGrpcServer(delivery *Delivery, auth *token.Middleware, cfg ServerConfig) *grpcServer {
+	return &grpcServer{delivery: delivery, auth: auth, config: cfg}
+}
+
+func (s *grpcServer) Serve() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", s.config.Port))
+	if err != nil {
+		return err
+	}
+
+	srv := grpc.NewServer(grpc.UnaryInterceptor(s.authenticate))
+	contract.RegisterContractServiceServer(srv, s.delivery)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+
+		log.Print("shutting down gRPC server")
+
+		srv.GracefulStop()
+	}()
+
+	log.Printf("starting gRPC server on %s", listener.Addr())
+
+	err = srv.Serve(listener)
+	if err != nil {
+		return err
+	}
+
+	log.Print("stopped gRPC server")
+
+	return nil
+}
+
+// authenticate puts the identity of the bearer token in the "authorization"
+// metadata into the context, like token.Middleware.Authenticate does for
+// HTTP. Calls without one pass through anonymously.
+func (s *grpcServer) authenticate(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(values) == 0 {
+		return handler(ctx, req)
+	}
+
+	bearer, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok || bearer == "" {
+		return nil, status.Error(codes.Unauthenticated, "invalid or missing authentication token")
+	}
+
+	identity, err := s.auth.Identify(ctx, bearer)
+	if err != nil {
+		switch {
+		case errors.Is(err, token.ErrInvalidToken), errors.Is(err, token.ErrExpiredToken):
+			return nil, status.Error(codes.Unauthenticated, "invalid or missing authentication token")
+		case errors.Is(err, token.ErrUnavailable):
+			log.Print(err)
+			return nil, status.Error(codes.Unavailable, "authentication is temporarily unavailable")
+		default:
+			log.Print(err)
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+	}
+
+	return handler(token.ContextWithIdentity(ctx, identity), req)
+}
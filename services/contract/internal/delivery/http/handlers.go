@@ -2,11 +2,19 @@ package http
 
 import (
 	"errors"
+	"fmt"
+	"microservices/pkg/diff"
+	"microservices/pkg/privacy"
 	"microservices/pkg/request"
+	"microservices/pkg/token"
 	"microservices/pkg/validator"
 	"microservices/services/contract/internal/repository"
 	"microservices/services/contract/internal/usecase"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
 )
 
 type ContractHandler struct {
@@ -26,12 +34,16 @@ func (h *ContractHandler) CreateContractHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	identity, _ := token.IdentityFromContext(r.Context())
+
 	contract := usecase.CreateContractDTO{
-		Title: input.Title,
-		Desc:  input.Desc,
+		Title:     input.Title,
+		Desc:      input.Desc,
+		CreatedBy: identity.UserID,
+		OrgID:     identity.OrgID,
 	}
 
-	err = h.contractService.CreateContract(r.Context(), contract)
+	created, err := h.contractService.CreateContract(r.Context(), contract)
 	if err != nil {
 		switch {
 		case errors.Is(err, usecase.ErrFailedValidation):
@@ -46,7 +58,7 @@ func (h *ContractHandler) CreateContractHandler(w http.ResponseWriter, r *http.R
 		}
 	}
 
-	err = request.WriteJSON(w, http.StatusCreated, map[string]any{"contract": contract}, nil)
+	err = request.WriteJSON(w, http.StatusCreated, map[string]any{"contract": created}, nil)
 	if err != nil {
 		request.ServerErrorResponse(w, r, err)
 		return
@@ -61,7 +73,293 @@ func (h *ContractHandler) ShowContractHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	contract, err := h.contractService.GetContractByID(r.Context(), id)
+	orgID, _ := token.OrgIDFromContext(r.Context())
+
+	contract, err := h.contractService.GetContractByID(r.Context(), orgID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	headers := http.Header{}
+	headers.Set("ETag", etag(contract.Version))
+
+	err = request.WriteJSON(w, http.StatusOK, map[string]any{"contract": contract}, headers)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// UpdateContractHandler applies a partial update. The version the client
+// last saw can be sent as If-Match, with the ETag of the contract, or as
+// version in the body; the update is refused if the contract has changed
+// since.
+func (h *ContractHandler) UpdateContractHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	var input usecase.UpdateContractDTO
+
+	err = request.ReadJSON(w, r, &input)
+	if err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	identity, _ := token.IdentityFromContext(r.Context())
+
+	version, ok := h.matchIfMatch(w, r, identity.OrgID, id)
+	if !ok {
+		return
+	}
+	if version != nil {
+		if input.Version != nil && *input.Version != *version {
+			request.EditConflictResponse(w, r)
+			return
+		}
+		input.Version = version
+	}
+
+	input.UpdatedBy = identity.UserID
+
+	contract, err := h.contractService.UpdateContract(r.Context(), identity.OrgID, id, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		case errors.Is(err, usecase.ErrEditConflict):
+			request.EditConflictResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrContractLocked):
+			request.ErrorResponse(w, r, http.StatusConflict, err.Error())
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	headers := http.Header{}
+	headers.Set("ETag", etag(contract.Version))
+
+	err = request.WriteJSON(w, http.StatusOK, map[string]any{"contract": contract}, headers)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// TransitionContractHandler moves a contract to another status, given with
+// an optional reason as {"status": "review", "reason": "..."}. Like updates,
+// it can be made conditional with If-Match or a version in the body.
+func (h *ContractHandler) TransitionContractHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	var input usecase.TransitionContractDTO
+
+	err = request.ReadJSON(w, r, &input)
+	if err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	identity, _ := token.IdentityFromContext(r.Context())
+
+	version, ok := h.matchIfMatch(w, r, identity.OrgID, id)
+	if !ok {
+		return
+	}
+	if version != nil {
+		if input.Version != nil && *input.Version != *version {
+			request.EditConflictResponse(w, r)
+			return
+		}
+		input.Version = version
+	}
+
+	input.ActorID = identity.UserID
+
+	contract, err := h.contractService.TransitionContract(r.Context(), identity.OrgID, id, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		case errors.Is(err, usecase.ErrEditConflict):
+			request.EditConflictResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrInvalidTransition):
+			request.ErrorResponse(w, r, http.StatusConflict, err.Error())
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	headers := http.Header{}
+	headers.Set("ETag", etag(contract.Version))
+
+	err = request.WriteJSON(w, http.StatusOK, map[string]any{"contract": contract}, headers)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (h *ContractHandler) ListTransitionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	orgID, _ := token.OrgIDFromContext(r.Context())
+
+	transitions, err := h.contractService.GetTransitions(r.Context(), orgID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	err = request.WriteJSON(w, http.StatusOK, map[string]any{"transitions": transitions}, nil)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (h *ContractHandler) DeleteContractHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	orgID, _ := token.OrgIDFromContext(r.Context())
+
+	err = h.contractService.DeleteContract(r.Context(), orgID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrEditConflict):
+			request.EditConflictResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrContractLocked):
+			request.ErrorResponse(w, r, http.StatusConflict, err.Error())
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ContractHandler) RestoreContractHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	orgID, _ := token.OrgIDFromContext(r.Context())
+
+	contract, err := h.contractService.RestoreContract(r.Context(), orgID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	headers := http.Header{}
+	headers.Set("ETag", etag(contract.Version))
+
+	err = request.WriteJSON(w, http.StatusOK, map[string]any{"contract": contract}, headers)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (h *ContractHandler) ListDeletedContractsHandler(w http.ResponseWriter, r *http.Request) {
+	var filters repository.Filters
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters.Page = request.ReadInt(qs, "page", 1, v)
+	filters.PageSize = request.ReadInt(qs, "page_size", 20, v)
+	filters.Sort = "-deleted_at"
+	filters.SortSafelist = []string{"-deleted_at"}
+
+	if repository.ValidateFilters(v, filters); !v.Valid() {
+		request.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	orgID, _ := token.OrgIDFromContext(r.Context())
+
+	contracts, err := h.contractService.GetDeletedContracts(r.Context(), orgID, filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	err = request.WriteJSON(w, http.StatusOK, map[string]any{"contracts": contracts}, nil)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (h *ContractHandler) ListRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	orgID, _ := token.OrgIDFromContext(r.Context())
+
+	revisions, err := h.contractService.GetRevisions(r.Context(), orgID, id)
 	if err != nil {
 		switch {
 		case errors.Is(err, repository.ErrRecordNotFound):
@@ -72,7 +370,116 @@ func (h *ContractHandler) ShowContractHandler(w http.ResponseWriter, r *http.Req
 			return
 		}
 	}
-	err = request.WriteJSON(w, http.StatusOK, map[string]any{"contract": contract}, nil)
+	err = request.WriteJSON(w, http.StatusOK, map[string]any{"revisions": revisions}, nil)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (h *ContractHandler) ShowRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	rev, err := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("rev"))
+	if err != nil || rev < 1 {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	orgID, _ := token.OrgIDFromContext(r.Context())
+
+	revision, err := h.contractService.GetRevision(r.Context(), orgID, id, rev)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	err = request.WriteJSON(w, http.StatusOK, map[string]any{"revision": revision}, nil)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// DiffRevisionsHandler compares revisions from and to of a contract. The
+// default format is a unified diff of the title and description as text;
+// format=json returns the edits instead, by line or, with
+// granularity=word, by word.
+func (h *ContractHandler) DiffRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	from := request.ReadInt(qs, "from", 0, v)
+	to := request.ReadInt(qs, "to", 0, v)
+	format := request.ReadString(qs, "format", "unified")
+	granularity := request.ReadString(qs, "granularity", "line")
+
+	v.Check(from > 0, "from", "must be a revision number")
+	v.Check(to > 0, "to", "must be a revision number")
+	v.Check(validator.In(format, "unified", "json"), "format", "must be unified or json")
+	v.Check(validator.In(granularity, "line", "word"), "granularity", "must be line or word")
+	v.Check(format == "json" || granularity == "line", "granularity", "must be line for unified diffs")
+	if !v.Valid() {
+		request.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	orgID, _ := token.OrgIDFromContext(r.Context())
+
+	fromRevision, toRevision, err := h.contractService.CompareRevisions(r.Context(), orgID, id, from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if format == "unified" {
+		name := func(revision int, field string) string {
+			return fmt.Sprintf("contract/%d/revisions/%d/%s", id, revision, field)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(diff.Unified(name(from, "title"), name(to, "title"), fromRevision.Title, toRevision.Title, 3) +
+			diff.Unified(name(from, "description"), name(to, "description"), fromRevision.Desc, toRevision.Desc, 3)))
+		return
+	}
+
+	compare := diff.Lines
+	if granularity == "word" {
+		compare = diff.Words
+	}
+
+	err = request.WriteJSON(w, http.StatusOK, map[string]any{"diff": map[string]any{
+		"from":        from,
+		"to":          to,
+		"granularity": granularity,
+		"title":       compare(fromRevision.Title, toRevision.Title),
+		"description": compare(fromRevision.Desc, toRevision.Desc),
+	}}, nil)
 	if err != nil {
 		request.ServerErrorResponse(w, r, err)
 		return
@@ -81,25 +488,38 @@ func (h *ContractHandler) ShowContractHandler(w http.ResponseWriter, r *http.Req
 
 func (h *ContractHandler) ListContractHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title string
+		Title  string
+		Status string
 		repository.Filters
 	}
 	v := validator.New()
 	qs := r.URL.Query()
 	input.Title = request.ReadString(qs, "title", "")
+	input.Status = request.ReadString(qs, "status", "")
 
 	input.Filters.Page = request.ReadInt(qs, "page", 1, v)
 	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, v)
 	input.Filters.Sort = request.ReadString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "title", "-id", "-title"}
+	input.Filters.SortSafelist = []string{"id", "title", "status", "-id", "-title", "-status"}
+
+	v.Check(input.Status == "" || validator.In(input.Status, usecase.Statuses...), "status", "must be draft, review, approved, signed or expired")
+	if !v.Valid() {
+		request.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	orgID, _ := token.OrgIDFromContext(r.Context())
 
-	contracts, err := h.contractService.GetContracts(r.Context(), input.Title, input.Filters)
+	contracts, err := h.contractService.GetContracts(r.Context(), orgID, input.Title, input.Status, input.Filters)
 
 	if err != nil {
 		switch {
 		case errors.Is(err, repository.ErrRecordNotFound):
 			request.NotFoundResponse(w, r)
 			return
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
 		default:
 			request.ServerErrorResponse(w, r, err)
 			return
@@ -111,3 +531,164 @@ func (h *ContractHandler) ListContractHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 }
+
+func (h *ContractHandler) PrivacyExportHandler(w http.ResponseWriter, r *http.Request) {
+	var subject privacy.Subject
+
+	err := request.ReadJSON(w, r, &subject)
+	if err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	data, err := h.contractService.ExportUserData(r.Context(), subject.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = request.WriteJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (h *ContractHandler) PrivacyEraseHandler(w http.ResponseWriter, r *http.Request) {
+	var subject privacy.Subject
+
+	err := request.ReadJSON(w, r, &subject)
+	if err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	erased, err := h.contractService.EraseUserData(r.Context(), subject.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = request.WriteJSON(w, http.StatusOK, privacy.ErasureResult{Erased: erased}, nil)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// AssignOrganizationHandler assigns contracts created before organizations
+// existed to an organization.
+func (h *ContractHandler) AssignOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	var input usecase.AssignOrganizationDTO
+
+	err := request.ReadJSON(w, r, &input)
+	if err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	assigned, err := h.contractService.AssignOrganization(r.Context(), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = request.WriteJSON(w, http.StatusOK, map[string]any{"assigned": assigned}, nil)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// etag is the entity tag of a contract at version.
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// matchIfMatch checks the If-Match header against the stored version of
+// the contract and returns that version, so the change only applies if it
+// is still current, or nil when the header is absent or "*". It writes the
+// response itself and returns false when the request must stop.
+func (h *ContractHandler) matchIfMatch(w http.ResponseWriter, r *http.Request, orgID, id int64) (*int64, bool) {
+	versions, err := readIfMatch(r)
+	if err != nil {
+		request.BadRequestResponse(w, r, err)
+		return nil, false
+	}
+	if versions == nil {
+		return nil, true
+	}
+
+	contract, err := h.contractService.GetContractByID(r.Context(), orgID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+		default:
+			request.ServerErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	for _, version := range versions {
+		if version == contract.Version {
+			return &version, true
+		}
+	}
+	request.EditConflictResponse(w, r)
+	return nil, false
+}
+
+// readIfMatch returns the versions named by the comma-separated If-Match
+// header, or nil when the header is absent or "*". If-Match compares tags
+// strongly, so weak tags are skipped and never match.
+func readIfMatch(r *http.Request) ([]int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	versions := []int64{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+
+		weak := strings.HasPrefix(tag, "W/")
+		opaque := strings.TrimPrefix(tag, "W/")
+
+		if len(opaque) < 2 || opaque[0] != '"' || opaque[len(opaque)-1] != '"' {
+			return nil, errors.New("invalid If-Match header")
+		}
+
+		version, err := strconv.ParseInt(opaque[1:len(opaque)-1], 10, 64)
+		if err != nil || version < 1 {
+			// Tags this service never issued cannot match.
+			continue
+		}
+
+		if !weak {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
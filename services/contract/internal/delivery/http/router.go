@@ -1,6 +1,8 @@
 package http
 
 import (
+	"microservices/pkg/privacy"
+	"microservices/pkg/token"
 	"microservices/services/contract/internal/usecase"
 	"net/http"
 
@@ -9,19 +11,39 @@ import (
 
 type router struct {
 	contract ContractHandler
+	auth     *token.Middleware
 }
 
-func NewRouter(bookService usecase.ContractService) *router {
-	return &router{contract: *NewHandler(bookService)}
+func NewRouter(bookService usecase.ContractService, auth *token.Middleware) *router {
+	return &router{contract: *NewHandler(bookService), auth: auth}
 }
 
 func (r *router) GetRoutes() http.Handler {
 
 	router := httprouter.New()
 
-	router.HandlerFunc(http.MethodPost, "/v1/books", r.contract.CreateContractHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/books/:id", r.contract.ShowContractHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/books", r.contract.ListContractHandler)
-
-	return router
+	router.HandlerFunc(http.MethodPost, "/v1/contracts", r.auth.RequirePermission("contracts:write", r.auth.RequireOrganization(r.contract.CreateContractHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/contracts/:id", r.auth.RequirePermission("contracts:read", r.auth.RequireOrganization(r.contract.ShowContractHandler)))
+	router.HandlerFunc(http.MethodPatch, "/v1/contracts/:id", r.auth.RequirePermission("contracts:write", r.auth.RequireOrganization(r.contract.UpdateContractHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/contracts/:id", r.auth.RequirePermission("contracts:write", r.auth.RequireOrganization(r.contract.DeleteContractHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/contracts/:id/transitions", r.auth.RequirePermission("contracts:write", r.auth.RequireOrganization(r.contract.TransitionContractHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/contracts/:id/transitions", r.auth.RequirePermission("contracts:read", r.auth.RequireOrganization(r.contract.ListTransitionsHandler)))
+	router.HandlerFunc(http.MethodPost, "/v1/contracts/:id/restore", r.auth.RequirePermission("contracts:write", r.auth.RequireOrganization(r.contract.RestoreContractHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/contracts/:id/revisions", r.auth.RequirePermission("contracts:read", r.auth.RequireOrganization(r.contract.ListRevisionsHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/contracts/:id/revisions/:rev", r.auth.RequirePermission("contracts:read", r.auth.RequireOrganization(r.contract.ShowRevisionHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/contracts/:id/diff", r.auth.RequirePermission("contracts:read", r.auth.RequireOrganization(r.contract.DiffRevisionsHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/contracts", r.auth.RequirePermission("contracts:read", r.auth.RequireOrganization(r.contract.ListContractHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/deleted-contracts", r.auth.RequirePermission("contracts:read", r.auth.RequireOrgAdmin(r.contract.ListDeletedContractsHandler)))
+
+	// The original routes, kept for existing clients.
+	router.HandlerFunc(http.MethodPost, "/v1/books", r.auth.RequirePermission("contracts:write", r.auth.RequireOrganization(r.contract.CreateContractHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/books/:id", r.auth.RequirePermission("contracts:read", r.auth.RequireOrganization(r.contract.ShowContractHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/books", r.auth.RequirePermission("contracts:read", r.auth.RequireOrganization(r.contract.ListContractHandler)))
+
+	router.HandlerFunc(http.MethodPost, "/v1/admin/contracts/assign-organization", r.auth.RequirePermission("tenants:admin", r.contract.AssignOrganizationHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/privacy/export", r.auth.RequirePermission(privacy.Permission, r.contract.PrivacyExportHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/privacy/erase", r.auth.RequirePermission(privacy.Permission, r.contract.PrivacyEraseHandler))
+
+	return r.auth.Authenticate(router)
 }
@@ -3,9 +3,36 @@ package domain
 import "time"
 
 type Contract struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"-"`
-	Title     string    `json:"title"`
-	Desc      string    `json:"description,omitempty"`
-	Version   int64     `json:"version"`
+	ID        int64      `json:"id"`
+	CreatedAt time.Time  `json:"-"`
+	Title     string     `json:"title"`
+	Desc      string     `json:"description,omitempty"`
+	Version   int64      `json:"version"`
+	Status    string     `json:"status"`
+	CreatedBy *int64     `json:"-"`
+	OrgID     int64      `json:"-"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// Revision is the content of a contract as saved by one create or update.
+// Revisions are numbered from 1 for each contract and never change.
+type Revision struct {
+	ContractID int64     `json:"contractId"`
+	Revision   int       `json:"revision"`
+	Version    int64     `json:"version"`
+	Title      string    `json:"title"`
+	Desc       string    `json:"description,omitempty"`
+	AuthorID   *int64    `json:"authorId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Transition is a change of a contract's status, with the reason given.
+type Transition struct {
+	ID         int64     `json:"id"`
+	ContractID int64     `json:"contractId"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Reason     string    `json:"reason,omitempty"`
+	ActorID    *int64    `json:"actorId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
 }
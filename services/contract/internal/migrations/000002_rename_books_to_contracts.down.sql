@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS contracts RENAME TO books;
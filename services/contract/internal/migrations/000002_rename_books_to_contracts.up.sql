@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS books RENAME TO contracts;
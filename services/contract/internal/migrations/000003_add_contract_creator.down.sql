@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS contracts_created_by_idx;
+
+ALTER TABLE contracts DROP COLUMN IF EXISTS created_by;
@@ -0,0 +1,3 @@
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS created_by bigint;
+
+CREATE INDEX IF NOT EXISTS contracts_created_by_idx ON contracts (created_by);
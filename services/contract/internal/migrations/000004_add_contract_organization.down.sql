@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS contracts_org_id_idx;
+
+ALTER TABLE contracts DROP COLUMN IF EXISTS org_id;
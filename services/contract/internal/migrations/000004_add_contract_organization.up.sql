@@ -0,0 +1,5 @@
+-- Contracts created before organizations existed have no org_id and are
+-- not visible to any tenant until they are assigned one.
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS org_id bigint;
+
+CREATE INDEX IF NOT EXISTS contracts_org_id_idx ON contracts (org_id, id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS contracts_deleted_at_idx;
+
+ALTER TABLE contracts DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,3 @@
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS contracts_deleted_at_idx ON contracts (deleted_at) WHERE deleted_at IS NOT NULL;
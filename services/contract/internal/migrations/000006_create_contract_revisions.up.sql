@@ -0,0 +1,21 @@
+-- Every create and update of a contract adds a revision; rows are never
+-- changed afterwards, except that erasing a user clears their author_id.
+-- Revisions go when their contract is purged.
+CREATE TABLE IF NOT EXISTS contract_revisions (
+    contract_id bigint NOT NULL REFERENCES contracts ON DELETE CASCADE,
+    revision integer NOT NULL,
+    version integer NOT NULL,
+    title text NOT NULL,
+    description text NOT NULL,
+    author_id bigint,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (contract_id, revision)
+);
+
+CREATE INDEX IF NOT EXISTS contract_revisions_author_id_idx ON contract_revisions (author_id);
+
+-- Existing contracts start their history with their current content.
+INSERT INTO contract_revisions (contract_id, revision, version, title, description, author_id, created_at)
+SELECT id, 1, version, title, description, created_by, created_at
+FROM contracts
+ON CONFLICT DO NOTHING;
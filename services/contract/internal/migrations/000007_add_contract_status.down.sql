@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS contract_transitions;
+
+DROP INDEX IF EXISTS contracts_org_id_status_idx;
+
+ALTER TABLE contracts DROP CONSTRAINT IF EXISTS contracts_status_check;
+ALTER TABLE contracts DROP COLUMN IF EXISTS status;
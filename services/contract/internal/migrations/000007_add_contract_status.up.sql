@@ -0,0 +1,21 @@
+ALTER TABLE contracts ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'draft';
+
+ALTER TABLE contracts ADD CONSTRAINT contracts_status_check
+    CHECK (status IN ('draft', 'review', 'approved', 'signed', 'expired'));
+
+CREATE INDEX IF NOT EXISTS contracts_org_id_status_idx ON contracts (org_id, status);
+
+-- Every change of status is recorded with the reason given for it. As with
+-- revisions, erasing a user only clears their actor_id.
+CREATE TABLE IF NOT EXISTS contract_transitions (
+    id bigserial PRIMARY KEY,
+    contract_id bigint NOT NULL REFERENCES contracts ON DELETE CASCADE,
+    from_status text NOT NULL,
+    to_status text NOT NULL,
+    reason text NOT NULL DEFAULT '',
+    actor_id bigint,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS contract_transitions_contract_id_idx ON contract_transitions (contract_id);
+CREATE INDEX IF NOT EXISTS contract_transitions_actor_id_idx ON contract_transitions (actor_id);
@@ -7,10 +7,12 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"microservices/services/contract/internal/domain"
+	"time"
 )
 
 var (
 	ErrRecordNotFound = errors.New("record not found")
+	ErrEditConflict   = errors.New("edit conflict")
 )
 
 type Repo struct {
@@ -19,44 +21,80 @@ type Repo struct {
 
 type Contract interface {
 	Create(ctx context.Context, contract *domain.Contract) error
-	GetByID(ctx context.Context, id int64) (*domain.Contract, error)
-	GetAll(ctx context.Context, title string, filters Filters) ([]*domain.Contract, error)
-	Delete(ctx context.Context, id int64) error
+	GetByID(ctx context.Context, orgID, id int64) (*domain.Contract, error)
+	GetAll(ctx context.Context, orgID int64, title, status string, filters Filters) ([]*domain.Contract, error)
+	GetAllDeleted(ctx context.Context, orgID int64, filters Filters) ([]*domain.Contract, error)
+	Update(ctx context.Context, contract *domain.Contract, status, reason string, editorID *int64) error
+	Transition(ctx context.Context, contract *domain.Contract, status, reason string, actorID *int64) error
+	GetTransitions(ctx context.Context, contractID int64) ([]*domain.Transition, error)
+	Delete(ctx context.Context, orgID, id, version int64) error
+	Restore(ctx context.Context, orgID, id int64) (*domain.Contract, error)
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	GetRevisions(ctx context.Context, contractID int64) ([]*domain.Revision, error)
+	GetRevision(ctx context.Context, contractID int64, revision int) (*domain.Revision, error)
+	GetAllCreatedBy(ctx context.Context, userID int64) ([]*domain.Contract, error)
+	GetRevisionsAuthoredBy(ctx context.Context, userID int64) ([]*domain.Revision, error)
+	GetTransitionsMadeBy(ctx context.Context, userID int64) ([]*domain.Transition, error)
+	ClearCreator(ctx context.Context, userID int64) (int64, error)
+	AssignOrganization(ctx context.Context, orgID int64, createdBy *int64) (int64, error)
 }
 
 func NewRepo(db *pgxpool.Pool) *Repo {
 	return &Repo{db: db}
 }
 
+// Create stores the contract in contract.OrgID together with its first
+// revision.
 func (s *Repo) Create(ctx context.Context, contract *domain.Contract) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
-		INSERT INTO contracts (title, description)
-		VALUES ($1, $2)
-		RETURNING id, created_at, version`
+		INSERT INTO contracts (title, description, created_by, org_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, version, status`
+
+	args := []interface{}{contract.Title, contract.Desc, contract.CreatedBy, contract.OrgID}
 
-	args := []interface{}{contract.Title, contract.Desc}
+	err = tx.QueryRow(ctx, query, args...).Scan(&contract.ID, &contract.CreatedAt, &contract.Version, &contract.Status)
+	if err != nil {
+		return err
+	}
 
-	return s.db.QueryRow(ctx, query, args...).Scan(&contract.ID, &contract.CreatedAt, &contract.Version)
+	err = insertRevision(ctx, tx, contract, contract.CreatedBy)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (s *Repo) GetByID(ctx context.Context, id int64) (*domain.Contract, error) {
-	if id < 1 {
+// GetByID returns the contract if it belongs to the organization.
+func (s *Repo) GetByID(ctx context.Context, orgID, id int64) (*domain.Contract, error) {
+	if id < 1 || orgID < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	query := `
-		SELECT id, created_at, title, description, version
+		SELECT id, created_at, title, description, version, status, created_by, org_id, deleted_at
 		FROM contracts
-		WHERE id = $1`
+		WHERE id = $1 AND org_id = $2 AND deleted_at IS NULL`
 
 	var contract domain.Contract
 
-	err := s.db.QueryRow(ctx, query, id).Scan(
+	err := s.db.QueryRow(ctx, query, id, orgID).Scan(
 		&contract.ID,
 		&contract.CreatedAt,
 		&contract.Title,
 		&contract.Desc,
 		&contract.Version,
+		&contract.Status,
+		&contract.CreatedBy,
+		&contract.OrgID,
+		&contract.DeletedAt,
 	)
 
 	if err != nil {
@@ -71,15 +109,19 @@ func (s *Repo) GetByID(ctx context.Context, id int64) (*domain.Contract, error)
 	return &contract, nil
 }
 
-func (s *Repo) GetAll(ctx context.Context, title string, filters Filters) ([]*domain.Contract, error) {
+// GetAll returns the organization's contracts matching title and, unless it
+// is empty, status.
+func (s *Repo) GetAll(ctx context.Context, orgID int64, title, status string, filters Filters) ([]*domain.Contract, error) {
 	query := fmt.Sprintf(`
-		SELECT id, created_at, title, description, version
+		SELECT id, created_at, title, description, version, status, created_by, org_id, deleted_at
 		FROM contracts
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		WHERE org_id = $1 AND deleted_at IS NULL
+		AND (to_tsvector('simple', title) @@ plainto_tsquery('simple', $2) OR $2 = '')
+		AND (status = $3 OR $3 = '')
 		ORDER BY %s %s, id ASC
-		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+		LIMIT $4 OFFSET $5`, filters.sortColumn(), filters.sortDirection())
 
-	args := []any{title, filters.limit(), filters.offset()}
+	args := []any{orgID, title, status, filters.limit(), filters.offset()}
 
 	rows, err := s.db.Query(ctx, query, args...)
 
@@ -105,6 +147,10 @@ func (s *Repo) GetAll(ctx context.Context, title string, filters Filters) ([]*do
 			&contract.Title,
 			&contract.Desc,
 			&contract.Version,
+			&contract.Status,
+			&contract.CreatedBy,
+			&contract.OrgID,
+			&contract.DeletedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -119,23 +165,521 @@ func (s *Repo) GetAll(ctx context.Context, title string, filters Filters) ([]*do
 	return contracts, nil
 }
 
-func (s *Repo) Delete(ctx context.Context, id int64) error {
-	if id < 1 {
+// Update saves the title and description as a new revision if the contract
+// is still at the version it was read at, and moves it to the next version.
+// A status other than the contract's current one is recorded as a
+// transition with reason, made by the editor.
+func (s *Repo) Update(ctx context.Context, contract *domain.Contract, status, reason string, editorID *int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE contracts
+		SET title = $1, description = $2, status = $3, version = version + 1
+		WHERE id = $4 AND org_id = $5 AND version = $6 AND deleted_at IS NULL
+		RETURNING version`
+
+	args := []any{contract.Title, contract.Desc, status, contract.ID, contract.OrgID, contract.Version}
+
+	err = tx.QueryRow(ctx, query, args...).Scan(&contract.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = insertRevision(ctx, tx, contract, editorID)
+	if err != nil {
+		return err
+	}
+
+	if status != contract.Status {
+		query = `
+			INSERT INTO contract_transitions (contract_id, from_status, to_status, reason, actor_id)
+			VALUES ($1, $2, $3, $4, $5)`
+
+		_, err = tx.Exec(ctx, query, contract.ID, contract.Status, status, reason, editorID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	contract.Status = status
+	return nil
+}
+
+// Transition moves the contract from its current status to status if it is
+// still at the version it was read at, and records the change.
+func (s *Repo) Transition(ctx context.Context, contract *domain.Contract, status, reason string, actorID *int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE contracts
+		SET status = $1, version = version + 1
+		WHERE id = $2 AND org_id = $3 AND version = $4 AND status = $5 AND deleted_at IS NULL
+		RETURNING version`
+
+	args := []any{status, contract.ID, contract.OrgID, contract.Version, contract.Status}
+
+	err = tx.QueryRow(ctx, query, args...).Scan(&contract.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	query = `
+		INSERT INTO contract_transitions (contract_id, from_status, to_status, reason, actor_id)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err = tx.Exec(ctx, query, contract.ID, contract.Status, status, reason, actorID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	contract.Status = status
+	return nil
+}
+
+const transitionQuery = `
+		SELECT id, contract_id, from_status, to_status, reason, actor_id, created_at
+		FROM contract_transitions`
+
+// GetTransitions lists the status changes of a contract, oldest first.
+func (s *Repo) GetTransitions(ctx context.Context, contractID int64) ([]*domain.Transition, error) {
+	query := transitionQuery + `
+		WHERE contract_id = $1
+		ORDER BY id`
+
+	return s.getTransitions(ctx, query, contractID)
+}
+
+func (s *Repo) getTransitions(ctx context.Context, query string, args ...any) ([]*domain.Transition, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transitions := []*domain.Transition{}
+
+	for rows.Next() {
+		var transition domain.Transition
+
+		err := rows.Scan(
+			&transition.ID,
+			&transition.ContractID,
+			&transition.From,
+			&transition.To,
+			&transition.Reason,
+			&transition.ActorID,
+			&transition.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, &transition)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transitions, nil
+}
+
+// insertRevision records the current content of the contract. The row lock
+// taken by the insert or update of the contract in the same transaction
+// keeps revision numbers from colliding.
+func insertRevision(ctx context.Context, tx pgx.Tx, contract *domain.Contract, authorID *int64) error {
+	query := `
+		INSERT INTO contract_revisions (contract_id, revision, version, title, description, author_id)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5
+		FROM contract_revisions
+		WHERE contract_id = $1`
+
+	_, err := tx.Exec(ctx, query, contract.ID, contract.Version, contract.Title, contract.Desc, authorID)
+	return err
+}
+
+// GetRevisions lists the revisions of a contract, oldest first, without
+// their descriptions.
+func (s *Repo) GetRevisions(ctx context.Context, contractID int64) ([]*domain.Revision, error) {
+	query := `
+		SELECT contract_id, revision, version, title, author_id, created_at
+		FROM contract_revisions
+		WHERE contract_id = $1
+		ORDER BY revision`
+
+	rows, err := s.db.Query(ctx, query, contractID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*domain.Revision{}
+
+	for rows.Next() {
+		var revision domain.Revision
+
+		err := rows.Scan(
+			&revision.ContractID,
+			&revision.Revision,
+			&revision.Version,
+			&revision.Title,
+			&revision.AuthorID,
+			&revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (s *Repo) GetRevision(ctx context.Context, contractID int64, revision int) (*domain.Revision, error) {
+	if revision < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT contract_id, revision, version, title, description, author_id, created_at
+		FROM contract_revisions
+		WHERE contract_id = $1 AND revision = $2`
+
+	var r domain.Revision
+
+	err := s.db.QueryRow(ctx, query, contractID, revision).Scan(
+		&r.ContractID,
+		&r.Revision,
+		&r.Version,
+		&r.Title,
+		&r.Desc,
+		&r.AuthorID,
+		&r.CreatedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &r, nil
+}
+
+// Delete moves the contract to the trash if it is still at version. It is
+// hidden from everything but GetAllDeleted until it is restored or purged.
+func (s *Repo) Delete(ctx context.Context, orgID, id, version int64) error {
+	if id < 1 || orgID < 1 {
 		return ErrRecordNotFound
 	}
 
 	query := `
-		DELETE FROM contracts
-		WHERE id = $1`
+		UPDATE contracts
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND org_id = $2 AND version = $3 AND deleted_at IS NULL`
 
-	result, err := s.db.Exec(ctx, query, id)
+	result, err := s.db.Exec(ctx, query, id, orgID, version)
 	if err != nil {
 		return err
 	}
 
 	if result.RowsAffected() == 0 {
-		return ErrRecordNotFound
+		return ErrEditConflict
 	}
 
 	return nil
 }
+
+// Restore takes a deleted contract out of the trash.
+func (s *Repo) Restore(ctx context.Context, orgID, id int64) (*domain.Contract, error) {
+	if id < 1 || orgID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE contracts
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND org_id = $2 AND deleted_at IS NOT NULL
+		RETURNING id, created_at, title, description, version, status, created_by, org_id, deleted_at`
+
+	var contract domain.Contract
+
+	err := s.db.QueryRow(ctx, query, id, orgID).Scan(
+		&contract.ID,
+		&contract.CreatedAt,
+		&contract.Title,
+		&contract.Desc,
+		&contract.Version,
+		&contract.Status,
+		&contract.CreatedBy,
+		&contract.OrgID,
+		&contract.DeletedAt,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &contract, nil
+}
+
+// GetAllDeleted returns the organization's deleted contracts, most recently
+// deleted first.
+func (s *Repo) GetAllDeleted(ctx context.Context, orgID int64, filters Filters) ([]*domain.Contract, error) {
+	query := `
+		SELECT id, created_at, title, description, version, status, created_by, org_id, deleted_at
+		FROM contracts
+		WHERE org_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.Query(ctx, query, orgID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contracts := []*domain.Contract{}
+
+	for rows.Next() {
+		var contract domain.Contract
+
+		err := rows.Scan(
+			&contract.ID,
+			&contract.CreatedAt,
+			&contract.Title,
+			&contract.Desc,
+			&contract.Version,
+			&contract.Status,
+			&contract.CreatedBy,
+			&contract.OrgID,
+			&contract.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		contracts = append(contracts, &contract)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return contracts, nil
+}
+
+// Purge removes contracts that were deleted before deletedBefore for good.
+// Signed and expired contracts are kept, whether or not they are deleted.
+func (s *Repo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	query := `
+		DELETE FROM contracts
+		WHERE deleted_at < $1 AND status NOT IN ('signed', 'expired')`
+
+	result, err := s.db.Exec(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// GetAllCreatedBy returns the contracts the user created in any
+// organization, deleted ones included; it serves privacy requests, which
+// follow the person rather than the tenant.
+func (s *Repo) GetAllCreatedBy(ctx context.Context, userID int64) ([]*domain.Contract, error) {
+	query := `
+		SELECT id, created_at, title, description, version, status, created_by, org_id, deleted_at
+		FROM contracts
+		WHERE created_by = $1
+		ORDER BY id`
+
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contracts := []*domain.Contract{}
+
+	for rows.Next() {
+		var contract domain.Contract
+
+		err := rows.Scan(
+			&contract.ID,
+			&contract.CreatedAt,
+			&contract.Title,
+			&contract.Desc,
+			&contract.Version,
+			&contract.Status,
+			&contract.CreatedBy,
+			&contract.OrgID,
+			&contract.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		contracts = append(contracts, &contract)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return contracts, nil
+}
+
+// GetRevisionsAuthoredBy returns the revisions the user saved in any
+// organization, for privacy requests like GetAllCreatedBy.
+func (s *Repo) GetRevisionsAuthoredBy(ctx context.Context, userID int64) ([]*domain.Revision, error) {
+	query := `
+		SELECT contract_id, revision, version, title, description, author_id, created_at
+		FROM contract_revisions
+		WHERE author_id = $1
+		ORDER BY contract_id, revision`
+
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*domain.Revision{}
+
+	for rows.Next() {
+		var revision domain.Revision
+
+		err := rows.Scan(
+			&revision.ContractID,
+			&revision.Revision,
+			&revision.Version,
+			&revision.Title,
+			&revision.Desc,
+			&revision.AuthorID,
+			&revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetTransitionsMadeBy returns the status changes the user made in any
+// organization, for privacy requests like GetAllCreatedBy.
+func (s *Repo) GetTransitionsMadeBy(ctx context.Context, userID int64) ([]*domain.Transition, error) {
+	query := transitionQuery + `
+		WHERE actor_id = $1
+		ORDER BY id`
+
+	return s.getTransitions(ctx, query, userID)
+}
+
+// ClearCreator detaches the user from the contracts they created, the
+// revisions they authored and the status changes they made, and returns how
+// many rows were changed. The rows themselves are kept.
+func (s *Repo) ClearCreator(ctx context.Context, userID int64) (int64, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE contracts
+		SET created_by = NULL, version = version + 1
+		WHERE created_by = $1`
+
+	contracts, err := tx.Exec(ctx, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `
+		UPDATE contract_revisions
+		SET author_id = NULL
+		WHERE author_id = $1`
+
+	revisions, err := tx.Exec(ctx, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	query = `
+		UPDATE contract_transitions
+		SET actor_id = NULL
+		WHERE actor_id = $1`
+
+	transitions, err := tx.Exec(ctx, query, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return contracts.RowsAffected() + revisions.RowsAffected() + transitions.RowsAffected(), nil
+}
+
+// AssignOrganization moves the contracts that have no organization, and
+// were created by createdBy unless it is nil, to orgID. Contracts that
+// already belong to an organization are never moved.
+func (s *Repo) AssignOrganization(ctx context.Context, orgID int64, createdBy *int64) (int64, error) {
+	query := `
+		UPDATE contracts
+		SET org_id = $1, version = version + 1
+		WHERE org_id IS NULL AND ($2::bigint IS NULL OR created_by = $2)`
+
+	result, err := s.db.Exec(ctx, query, orgID, createdBy)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
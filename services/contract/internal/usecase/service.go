@@ -3,59 +3,132 @@ package usecase
 import (
 	"context"
 	"errors"
+	"log"
 	"microservices/pkg/validator"
 	"microservices/services/contract/internal/domain"
 	"microservices/services/contract/internal/repository"
+	"time"
 )
 
 var (
 	ErrFailedValidation = errors.New("validation failed")
 	ErrDuplicate        = errors.New("record duplication")
+	ErrEditConflict     = errors.New("edit conflict")
+	// ErrInvalidTransition is returned for a status change the transition
+	// table does not allow.
+	ErrInvalidTransition = errors.New("invalid status transition")
+	// ErrContractLocked is returned for edits to, and deletion of, a signed
+	// or expired contract.
+	ErrContractLocked = errors.New("contract is signed and cannot be changed")
 )
 
 type CreateContractDTO struct {
 	Title string `json:"title"`
 	Desc  string `json:"description"`
+	// CreatedBy is the user creating the contract, taken from the token.
+	CreatedBy int64 `json:"-"`
+	// OrgID is the organization the contract belongs to, taken from the token.
+	OrgID int64 `json:"-"`
+}
+
+// UpdateContractDTO changes the fields that are set. Version, when set,
+// must match the stored version of the contract.
+type UpdateContractDTO struct {
+	Title   *string `json:"title"`
+	Desc    *string `json:"description"`
+	Version *int64  `json:"version"`
+	// UpdatedBy is the user making the change, taken from the token.
+	UpdatedBy int64 `json:"-"`
+}
+
+// TransitionContractDTO moves a contract to another status. Version, when
+// set, must match the stored version of the contract.
+type TransitionContractDTO struct {
+	Status  string `json:"status"`
+	Reason  string `json:"reason"`
+	Version *int64 `json:"version"`
+	// ActorID is the user making the change, taken from the token.
+	ActorID int64 `json:"-"`
+}
+
+// AssignOrganizationDTO assigns contracts created before organizations
+// existed. CreatedBy, when set, limits it to the contracts of that user.
+type AssignOrganizationDTO struct {
+	OrgID     int64  `json:"org_id"`
+	CreatedBy *int64 `json:"created_by"`
+}
+
+// UserData is everything held about a user, for privacy exports.
+type UserData struct {
+	Contracts   []*domain.Contract   `json:"contracts"`
+	Revisions   []*domain.Revision   `json:"revisions"`
+	Transitions []*domain.Transition `json:"transitions"`
 }
 
 type ContractService interface {
-	CreateContract(ctx context.Context, input CreateContractDTO) error
-	GetContractByID(ctx context.Context, id int64) (*domain.Contract, error)
-	GetContracts(ctx context.Context, title string, filters repository.Filters) ([]*domain.Contract, error)
+	CreateContract(ctx context.Context, input CreateContractDTO) (*domain.Contract, error)
+	GetContractByID(ctx context.Context, orgID, id int64) (*domain.Contract, error)
+	GetContracts(ctx context.Context, orgID int64, title, status string, filters repository.Filters) ([]*domain.Contract, error)
+	UpdateContract(ctx context.Context, orgID, id int64, input UpdateContractDTO) (*domain.Contract, error)
+	TransitionContract(ctx context.Context, orgID, id int64, input TransitionContractDTO) (*domain.Contract, error)
+	GetTransitions(ctx context.Context, orgID, id int64) ([]*domain.Transition, error)
+	DeleteContract(ctx context.Context, orgID, id int64) error
+	RestoreContract(ctx context.Context, orgID, id int64) (*domain.Contract, error)
+	GetDeletedContracts(ctx context.Context, orgID int64, filters repository.Filters) ([]*domain.Contract, error)
+	GetRevisions(ctx context.Context, orgID, id int64) ([]*domain.Revision, error)
+	GetRevision(ctx context.Context, orgID, id int64, revision int) (*domain.Revision, error)
+	CompareRevisions(ctx context.Context, orgID, id int64, from, to int) (*domain.Revision, *domain.Revision, error)
+	ExportUserData(ctx context.Context, userID int64) (*UserData, error)
+	EraseUserData(ctx context.Context, userID int64) (int64, error)
+	AssignOrganization(ctx context.Context, input AssignOrganizationDTO) (int64, error)
+}
+
+type Config struct {
+	// DeletedRetention is how long deleted contracts can be restored before
+	// PurgeDeletedContracts removes them for good.
+	DeletedRetention time.Duration
+	PurgeInterval    time.Duration
 }
 
 type service struct {
-	repo repository.Contract
+	repo   repository.Contract
+	config Config
 }
 
-func New(repo repository.Contract) *service {
+func New(repo repository.Contract, cfg Config) *service {
 	return &service{
-		repo: repo,
+		repo:   repo,
+		config: cfg,
 	}
 }
 
-func (s *service) CreateContract(ctx context.Context, input CreateContractDTO) error {
+func (s *service) CreateContract(ctx context.Context, input CreateContractDTO) (*domain.Contract, error) {
 	contract := domain.Contract{
 		Title: input.Title,
 		Desc:  input.Desc,
+		OrgID: input.OrgID,
+	}
+	if input.CreatedBy > 0 {
+		contract.CreatedBy = &input.CreatedBy
 	}
 
 	v := validator.New()
 
+	v.Check(contract.OrgID > 0, "org_id", "must be provided")
 	if ValidateBook(v, &contract); !v.Valid() {
-		return ErrFailedValidation
+		return nil, ErrFailedValidation
 	}
 
 	err := s.repo.Create(ctx, &contract)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return &contract, nil
 }
 
-func (s *service) GetContractByID(ctx context.Context, id int64) (*domain.Contract, error) {
-	contract, err := s.repo.GetByID(ctx, id)
+func (s *service) GetContractByID(ctx context.Context, orgID, id int64) (*domain.Contract, error) {
+	contract, err := s.repo.GetByID(ctx, orgID, id)
 
 	if err != nil {
 		switch {
@@ -68,15 +141,18 @@ func (s *service) GetContractByID(ctx context.Context, id int64) (*domain.Contra
 	return contract, nil
 }
 
-func (s *service) GetContracts(ctx context.Context, title string, filters repository.Filters) ([]*domain.Contract, error) {
+// GetContracts lists the organization's contracts, filtered by title and,
+// unless it is empty, status.
+func (s *service) GetContracts(ctx context.Context, orgID int64, title, status string, filters repository.Filters) ([]*domain.Contract, error) {
 	v := validator.New()
 
+	v.Check(status == "" || validator.In(status, Statuses...), "status", "must be draft, review, approved, signed or expired")
 	if repository.ValidateFilters(v, filters); !v.Valid() {
 		return nil, ErrFailedValidation
 	}
 	var contracts []*domain.Contract
 
-	contracts, err := s.repo.GetAll(ctx, title, filters)
+	contracts, err := s.repo.GetAll(ctx, orgID, title, status, filters)
 
 	if err != nil {
 		switch {
@@ -89,9 +165,215 @@ func (s *service) GetContracts(ctx context.Context, title string, filters reposi
 	return contracts, err
 }
 
+func (s *service) UpdateContract(ctx context.Context, orgID, id int64, input UpdateContractDTO) (*domain.Contract, error) {
+	contract, err := s.GetContractByID(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Version != nil && *input.Version != contract.Version {
+		return nil, ErrEditConflict
+	}
+
+	if isLocked(contract) {
+		return nil, ErrContractLocked
+	}
+
+	edited := (input.Title != nil && *input.Title != contract.Title) || (input.Desc != nil && *input.Desc != contract.Desc)
+
+	if input.Title != nil {
+		contract.Title = *input.Title
+	}
+	if input.Desc != nil {
+		contract.Desc = *input.Desc
+	}
+
+	v := validator.New()
+
+	if ValidateBook(v, contract); !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	var editorID *int64
+	if input.UpdatedBy > 0 {
+		editorID = &input.UpdatedBy
+	}
+
+	// The reviewed or approved text is no longer the text, so the contract
+	// has to be reviewed again before it can be signed.
+	status, reason := contract.Status, ""
+	if edited && (contract.Status == StatusReview || contract.Status == StatusApproved) {
+		status, reason = StatusDraft, "edited after "+contract.Status
+	}
+
+	err = s.repo.Update(ctx, contract, status, reason, editorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			return nil, ErrEditConflict
+		default:
+			return nil, err
+		}
+	}
+	return contract, nil
+}
+
+// DeleteContract moves the contract to the trash, from where it can be
+// restored until it is purged. Signed and expired contracts cannot be
+// deleted.
+func (s *service) DeleteContract(ctx context.Context, orgID, id int64) error {
+	contract, err := s.GetContractByID(ctx, orgID, id)
+	if err != nil {
+		return err
+	}
+
+	if isLocked(contract) {
+		return ErrContractLocked
+	}
+
+	err = s.repo.Delete(ctx, orgID, id, contract.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) RestoreContract(ctx context.Context, orgID, id int64) (*domain.Contract, error) {
+	return s.repo.Restore(ctx, orgID, id)
+}
+
+func (s *service) GetDeletedContracts(ctx context.Context, orgID int64, filters repository.Filters) ([]*domain.Contract, error) {
+	v := validator.New()
+
+	if repository.ValidateFilters(v, filters); !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	return s.repo.GetAllDeleted(ctx, orgID, filters)
+}
+
+// GetRevisions lists the revisions of a contract that is not deleted.
+func (s *service) GetRevisions(ctx context.Context, orgID, id int64) ([]*domain.Revision, error) {
+	_, err := s.GetContractByID(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetRevisions(ctx, id)
+}
+
+func (s *service) GetRevision(ctx context.Context, orgID, id int64, revision int) (*domain.Revision, error) {
+	_, err := s.GetContractByID(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetRevision(ctx, id, revision)
+}
+
+// CompareRevisions returns two revisions of a contract, to be diffed.
+func (s *service) CompareRevisions(ctx context.Context, orgID, id int64, from, to int) (*domain.Revision, *domain.Revision, error) {
+	v := validator.New()
+	v.Check(from > 0, "from", "must be greater than zero")
+	v.Check(to > 0, "to", "must be greater than zero")
+	if !v.Valid() {
+		return nil, nil, ErrFailedValidation
+	}
+
+	fromRevision, err := s.GetRevision(ctx, orgID, id, from)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	toRevision, err := s.repo.GetRevision(ctx, id, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fromRevision, toRevision, nil
+}
+
+// PurgeDeletedContracts removes contracts deleted more than DeletedRetention
+// ago, every PurgeInterval, until ctx is cancelled. A PurgeInterval of zero
+// or less disables purging.
+func (s *service) PurgeDeletedContracts(ctx context.Context) {
+	if s.config.PurgeInterval <= 0 {
+		log.Print("purging of deleted contracts is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(s.config.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.repo.Purge(ctx, time.Now().Add(-s.config.DeletedRetention))
+		if err != nil {
+			log.Printf("purge deleted contracts: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d deleted contracts", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExportUserData returns the contracts created, the revisions authored and
+// the status changes made by the user.
+func (s *service) ExportUserData(ctx context.Context, userID int64) (*UserData, error) {
+	if userID < 1 {
+		return nil, ErrFailedValidation
+	}
+
+	contracts, err := s.repo.GetAllCreatedBy(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.repo.GetRevisionsAuthoredBy(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	transitions, err := s.repo.GetTransitionsMadeBy(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserData{Contracts: contracts, Revisions: revisions, Transitions: transitions}, nil
+}
+
+// EraseUserData pseudonymises the contracts, revisions and status changes
+// of the user by removing the link to them, and returns how many rows were changed.
+func (s *service) EraseUserData(ctx context.Context, userID int64) (int64, error) {
+	if userID < 1 {
+		return 0, ErrFailedValidation
+	}
+	return s.repo.ClearCreator(ctx, userID)
+}
+
+// AssignOrganization moves contracts without an organization to
+// input.OrgID and returns how many were moved.
+func (s *service) AssignOrganization(ctx context.Context, input AssignOrganizationDTO) (int64, error) {
+	v := validator.New()
+	v.Check(input.OrgID > 0, "org_id", "must be provided")
+	v.Check(input.CreatedBy == nil || *input.CreatedBy > 0, "created_by", "must be greater than zero")
+	if !v.Valid() {
+		return 0, ErrFailedValidation
+	}
+
+	return s.repo.AssignOrganization(ctx, input.OrgID, input.CreatedBy)
+}
+
 func ValidateBook(v *validator.Validator, contract *domain.Contract) {
 	v.Check(contract.Title != "", "title", "must be provided")
 	v.Check(len(contract.Title) <= 500, "title", "must not be more than 500 bytes long")
 	v.Check(contract.Desc != "", "description", "must be provided")
 	v.Check(len(contract.Desc) >= 1500, "description", "must be greater than 1500 characters")
+	v.Check(len(contract.Desc) <= 100_000, "description", "must not be more than 100000 bytes long")
 }
@@ -0,0 +1,152 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"microservices/services/contract/internal/domain"
+	"microservices/services/contract/internal/repository"
+	"strings"
+	"testing"
+)
+
+// fakeRepo holds a single contract; any method UpdateContract does not need
+// panics through the nil embedded interface.
+type fakeRepo struct {
+	repository.Contract
+
+	contract    domain.Contract
+	transitions []domain.Transition
+}
+
+func (r *fakeRepo) GetByID(ctx context.Context, orgID, id int64) (*domain.Contract, error) {
+	if orgID != r.contract.OrgID || id != r.contract.ID {
+		return nil, repository.ErrRecordNotFound
+	}
+	contract := r.contract
+	return &contract, nil
+}
+
+func (r *fakeRepo) Update(ctx context.Context, contract *domain.Contract, status, reason string, editorID *int64) error {
+	if contract.Version != r.contract.Version {
+		return repository.ErrEditConflict
+	}
+
+	if status != contract.Status {
+		r.transitions = append(r.transitions, domain.Transition{
+			ContractID: contract.ID,
+			From:       contract.Status,
+			To:         status,
+			Reason:     reason,
+			ActorID:    editorID,
+		})
+	}
+
+	contract.Version++
+	contract.Status = status
+	r.contract = *contract
+	return nil
+}
+
+func TestUpdateContractStatus(t *testing.T) {
+	title := "Service agreement, second edition"
+
+	tests := []struct {
+		name           string
+		status         string
+		title          string
+		wantStatus     string
+		wantTransition bool
+		wantErr        error
+	}{
+		{
+			name:       "draft stays draft",
+			status:     StatusDraft,
+			title:      title,
+			wantStatus: StatusDraft,
+		},
+		{
+			name:           "review goes back to draft",
+			status:         StatusReview,
+			title:          title,
+			wantStatus:     StatusDraft,
+			wantTransition: true,
+		},
+		{
+			name:           "approved goes back to draft",
+			status:         StatusApproved,
+			title:          title,
+			wantStatus:     StatusDraft,
+			wantTransition: true,
+		},
+		{
+			name:       "approved without changes stays approved",
+			status:     StatusApproved,
+			title:      "Service agreement",
+			wantStatus: StatusApproved,
+		},
+		{
+			name:    "signed is locked",
+			status:  StatusSigned,
+			title:   title,
+			wantErr: ErrContractLocked,
+		},
+		{
+			name:    "expired is locked",
+			status:  StatusExpired,
+			title:   title,
+			wantErr: ErrContractLocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{contract: domain.Contract{
+				ID:      1,
+				OrgID:   1,
+				Title:   "Service agreement",
+				Desc:    strings.Repeat("x", 1500),
+				Status:  tt.status,
+				Version: 3,
+			}}
+			s := New(repo, Config{})
+
+			editor := int64(7)
+			contract, err := s.UpdateContract(context.Background(), 1, 1, UpdateContractDTO{Title: &tt.title, UpdatedBy: editor})
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				if repo.contract.Status != tt.status || repo.contract.Version != 3 {
+					t.Errorf("locked contract was changed to %+v", repo.contract)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contract.Status != tt.wantStatus {
+				t.Errorf("got status %q, want %q", contract.Status, tt.wantStatus)
+			}
+
+			if !tt.wantTransition {
+				if len(repo.transitions) != 0 {
+					t.Errorf("got transitions %+v, want none", repo.transitions)
+				}
+				return
+			}
+
+			if len(repo.transitions) != 1 {
+				t.Fatalf("got %d transitions, want 1", len(repo.transitions))
+			}
+			transition := repo.transitions[0]
+			if transition.From != tt.status || transition.To != StatusDraft || transition.Reason == "" {
+				t.Errorf("got transition %+v, want %s to %s with a reason", transition, tt.status, StatusDraft)
+			}
+			if transition.ActorID == nil || *transition.ActorID != editor {
+				t.Errorf("got transition actor %v, want %d", transition.ActorID, editor)
+			}
+		})
+	}
+}
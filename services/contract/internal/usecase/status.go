@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"microservices/pkg/validator"
+	"microservices/services/contract/internal/domain"
+	"microservices/services/contract/internal/repository"
+)
+
+// Statuses a contract moves through. New contracts are drafts; editing a
+// contract in review or approved sends it back to draft, and once signed a
+// contract can no longer be edited, only expired.
+const (
+	StatusDraft    = "draft"
+	StatusReview   = "review"
+	StatusApproved = "approved"
+	StatusSigned   = "signed"
+	StatusExpired  = "expired"
+)
+
+var Statuses = []string{StatusDraft, StatusReview, StatusApproved, StatusSigned, StatusExpired}
+
+// transitions lists the statuses each status can move to. Sending a
+// contract back to draft needs a reason, so the author knows what to change.
+var transitions = map[string][]string{
+	StatusDraft:    {StatusReview},
+	StatusReview:   {StatusDraft, StatusApproved},
+	StatusApproved: {StatusDraft, StatusSigned},
+	StatusSigned:   {StatusExpired},
+}
+
+// TransitionContract moves the contract to input.Status if the transition
+// table allows it from the contract's current status.
+func (s *service) TransitionContract(ctx context.Context, orgID, id int64, input TransitionContractDTO) (*domain.Contract, error) {
+	v := validator.New()
+	v.Check(validator.In(input.Status, Statuses...), "status", "must be draft, review, approved, signed or expired")
+	v.Check(len(input.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+	if !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	contract, err := s.GetContractByID(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Version != nil && *input.Version != contract.Version {
+		return nil, ErrEditConflict
+	}
+
+	if !CanTransition(contract.Status, input.Status) {
+		return nil, fmt.Errorf("%w from %s to %s", ErrInvalidTransition, contract.Status, input.Status)
+	}
+	if input.Status == StatusDraft && input.Reason == "" {
+		return nil, fmt.Errorf("%w: a reason is needed to send a contract back to draft", ErrFailedValidation)
+	}
+
+	var actorID *int64
+	if input.ActorID > 0 {
+		actorID = &input.ActorID
+	}
+
+	err = s.repo.Transition(ctx, contract, input.Status, input.Reason, actorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			return nil, ErrEditConflict
+		default:
+			return nil, err
+		}
+	}
+	return contract, nil
+}
+
+// GetTransitions lists the status changes of a contract that is not
+// deleted.
+func (s *service) GetTransitions(ctx context.Context, orgID, id int64) ([]*domain.Transition, error) {
+	_, err := s.GetContractByID(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetTransitions(ctx, id)
+}
+
+// CanTransition reports whether a contract may move from one status to the
+// other.
+func CanTransition(from, to string) bool {
+	return validator.In(to, transitions[from]...)
+}
+
+// isLocked reports whether the contract's content is final.
+func isLocked(contract *domain.Contract) bool {
+	return contract.Status == StatusSigned || contract.Status == StatusExpired
+}
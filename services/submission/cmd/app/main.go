@@ -4,16 +4,27 @@ import (
 	"flag"
 	"log"
 	"microservices/pkg/store/postgres"
+	"microservices/pkg/token"
 	"microservices/services/submission/internal/delivery/http"
 	"microservices/services/submission/internal/repository"
 	"microservices/services/submission/internal/usecase"
 	"os"
+	"strings"
+	"time"
 )
 
 func main() {
 	dbConnCfg := postgres.ConnConfig{}
 	httpServerCfg := http.ServerConfig{}
 
+	tokenCfg := token.Config{HMACSecret: os.Getenv("TOKEN_KEY")}
+
+	var (
+		userServiceURL  string
+		sessionCacheTTL time.Duration
+		apiKeyCacheTTL  time.Duration
+	)
+
 	flag.IntVar(&httpServerCfg.Port, "http-port", 8080, "HTTP server port")
 	flag.StringVar(&httpServerCfg.ReadTimeout, "http-read-timeout", "10s", "HTTP read timeout")
 	flag.StringVar(&httpServerCfg.WriteTimeout, "http-write-timeout", "30s", "HTTP write timeout")
@@ -26,6 +37,14 @@ func main() {
 	flag.StringVar(&dbConnCfg.DbName, "pg-db-name", os.Getenv("POSTGRE_DB_NAME"), "Postgres DB name")
 	flag.IntVar(&dbConnCfg.MaxOpenConns, "pg-max-open-conns", 15, "Postgres max open connections")
 	flag.StringVar(&dbConnCfg.MaxIdleTime, "pg-max-idle-time", "15m", "Postgres max connection idle time")
+
+	flag.StringVar(&tokenCfg.Issuer, "token-issuer", "microservices/user", "JWT issuer")
+	flag.StringVar(&tokenCfg.Audience, "token-audience", "microservices", "JWT audience")
+	flag.StringVar(&tokenCfg.VerificationKeys, "token-verification-keys", "", "Comma-separated kid=path list of PEM public keys accepted for JWT verification")
+
+	flag.StringVar(&userServiceURL, "user-service-url", "http://localhost:4000", "Base URL of the user service, used to resolve API keys and check sessions; API keys are rejected when empty")
+	flag.DurationVar(&sessionCacheTTL, "session-cache-ttl", 30*time.Second, "How long the state of a session is cached before asking the user service again")
+	flag.DurationVar(&apiKeyCacheTTL, "api-key-cache-ttl", 30*time.Second, "How long a resolved or rejected API key is cached before asking the user service again")
 	flag.Parse()
 
 	db, err := postgres.OpenDB(dbConnCfg)
@@ -36,9 +55,21 @@ func main() {
 
 	log.Print("database connection pool established")
 
+	tokenManager, err := token.NewManagerFromConfig(tokenCfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	auth := token.NewMiddleware(tokenManager)
+	if userServiceURL != "" {
+		userServiceURL = strings.TrimSuffix(userServiceURL, "/")
+		auth.WithAPIKeys(token.NewRemoteAPIKeyResolver(userServiceURL+"/v1/api-keys/introspect", os.Getenv("INTROSPECTION_KEY"), apiKeyCacheTTL))
+		auth.WithSessionCheck(token.NewRemoteSessionChecker(userServiceURL+"/v1/user/sessions/current", sessionCacheTTL))
+	}
+
 	orderService := usecase.New(repository.NewOrderRepo(db.Pool))
 
-	httpServer := http.NewHttpServer(http.NewRouter(orderService).GetRoutes(), httpServerCfg)
+	httpServer := http.NewHttpServer(http.NewRouter(orderService, auth).GetRoutes(), httpServerCfg)
 
 	err = httpServer.Serve()
 	if err != nil {
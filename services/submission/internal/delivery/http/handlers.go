@@ -3,7 +3,9 @@ package http
 import (
 	"errors"
 	"fmt"
+	"microservices/pkg/privacy"
 	"microservices/pkg/request"
+	"microservices/pkg/token"
 	"microservices/services/submission/internal/repository"
 	"microservices/services/submission/internal/usecase"
 	"net/http"
@@ -25,9 +27,12 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	orgID, _ := token.OrgIDFromContext(r.Context())
+
 	input := usecase.CreateOrderDTO{
 		BookID: dto.BookID,
 		Email:  dto.Email,
+		OrgID:  orgID,
 	}
 
 	err := h.orderService.Create(r.Context(), input)
@@ -54,7 +59,14 @@ func (h *OrderHandler) ShowOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	fmt.Println(dto)
-	orders, err := h.orderService.Show(r.Context(), dto.Email)
+
+	identity, _ := token.IdentityFromContext(r.Context())
+	if dto.Email != identity.Email && !identity.HasPermission("submissions:read:any") {
+		request.NotPermittedResponse(w, r)
+		return
+	}
+
+	orders, err := h.orderService.Show(r.Context(), identity.OrgID, dto.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, repository.ErrRecordNotFound):
@@ -71,3 +83,83 @@ func (h *OrderHandler) ShowOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+func (h *OrderHandler) PrivacyExport(w http.ResponseWriter, r *http.Request) {
+	var subject privacy.Subject
+
+	if err := request.ReadJSON(w, r, &subject); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	orders, err := h.orderService.ExportUserData(r.Context(), subject.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	err = request.WriteJSON(w, http.StatusOK, map[string]any{"orders": orders}, nil)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (h *OrderHandler) PrivacyErase(w http.ResponseWriter, r *http.Request) {
+	var subject privacy.Subject
+
+	if err := request.ReadJSON(w, r, &subject); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	erased, err := h.orderService.EraseUserData(r.Context(), subject.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	err = request.WriteJSON(w, http.StatusOK, privacy.ErasureResult{Erased: erased}, nil)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// AssignOrganization assigns orders placed before organizations existed to
+// an organization.
+func (h *OrderHandler) AssignOrganization(w http.ResponseWriter, r *http.Request) {
+	var input usecase.AssignOrganizationDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	assigned, err := h.orderService.AssignOrganization(r.Context(), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	err = request.WriteJSON(w, http.StatusOK, map[string]any{"assigned": assigned}, nil)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+}
@@ -1,7 +1,9 @@
 package http
 
 import (
-	"microservices-go/services/order/internal/service"
+	"microservices/pkg/privacy"
+	"microservices/pkg/token"
+	"microservices/services/submission/internal/usecase"
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
@@ -9,18 +11,24 @@ import (
 
 type router struct {
 	order OrderHandler
+	auth  *token.Middleware
 }
 
-func NewRouter(orderService service.OrderService) *router {
-	return &router{order: *NewHandler(orderService)}
+func NewRouter(orderService usecase.OrderService, auth *token.Middleware) *router {
+	return &router{order: *NewHandler(orderService), auth: auth}
 }
 
 func (r *router) GetRoutes() http.Handler {
 
 	router := httprouter.New()
 
-	router.HandlerFunc(http.MethodPost, "/v1/submission/create", r.order.CreateOrder)
-	router.HandlerFunc(http.MethodPost, "/v1/submission/show", r.order.ShowOrder)
+	router.HandlerFunc(http.MethodPost, "/v1/submission/create", r.auth.RequirePermission("submissions:write", r.auth.RequireOrganization(r.order.CreateOrder)))
+	router.HandlerFunc(http.MethodPost, "/v1/submission/show", r.auth.RequirePermission("submissions:read", r.auth.RequireOrganization(r.order.ShowOrder)))
 
-	return router
+	router.HandlerFunc(http.MethodPost, "/v1/submission/assign-organization", r.auth.RequirePermission("tenants:admin", r.order.AssignOrganization))
+
+	router.HandlerFunc(http.MethodPost, "/v1/privacy/export", r.auth.RequirePermission(privacy.Permission, r.order.PrivacyExport))
+	router.HandlerFunc(http.MethodPost, "/v1/privacy/erase", r.auth.RequirePermission(privacy.Permission, r.order.PrivacyErase))
+
+	return r.auth.Authenticate(router)
 }
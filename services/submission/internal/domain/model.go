@@ -7,4 +7,5 @@ type Order struct {
 	BookID    int64     `json:"book_id,omitempty"`
 	Email     string    `json:"email,omitempty"`
 	CreatedAt time.Time `json:"createdAt,omitempty"`
+	OrgID     int64     `json:"-"`
 }
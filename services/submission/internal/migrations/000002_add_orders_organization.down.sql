@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS orders_org_id_email_idx;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS org_id;
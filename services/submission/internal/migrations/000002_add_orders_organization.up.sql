@@ -0,0 +1,5 @@
+-- Orders placed before organizations existed have no org_id and are not
+-- visible to any tenant until they are assigned one.
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS org_id bigint;
+
+CREATE INDEX IF NOT EXISTS orders_org_id_email_idx ON orders (org_id, email);
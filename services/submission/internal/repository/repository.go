@@ -20,20 +20,24 @@ type orderRepo struct {
 
 type Order interface {
 	Insert(ctx context.Context, order *domain.Order) error
-	GetByEmail(ctx context.Context, email *string) ([]*domain.Order, error)
+	GetByEmail(ctx context.Context, orgID int64, email *string) ([]*domain.Order, error)
+	GetAllByEmail(ctx context.Context, email string) ([]*domain.Order, error)
+	ReplaceEmail(ctx context.Context, email, replacement string) (int64, error)
+	AssignOrganization(ctx context.Context, orgID int64, email *string) (int64, error)
 }
 
 func NewOrderRepo(db *pgxpool.Pool) *orderRepo {
 	return &orderRepo{db: db}
 }
 
+// Insert stores the order in order.OrgID.
 func (s *orderRepo) Insert(ctx context.Context, order *domain.Order) error {
 	query := `
-	INSERT INTO orders (book_id, email)
-	VALUES ($1, $2)
+	INSERT INTO orders (book_id, email, org_id)
+	VALUES ($1, $2, $3)
 	RETURNING id, created_at`
 
-	args := []any{order.BookID, order.Email}
+	args := []any{order.BookID, order.Email, order.OrgID}
 
 	err := s.db.QueryRow(ctx, query, args...).Scan(&order.ID, &order.CreatedAt)
 	if err != nil {
@@ -42,12 +46,14 @@ func (s *orderRepo) Insert(ctx context.Context, order *domain.Order) error {
 	return nil
 }
 
-func (s *orderRepo) GetByEmail(ctx context.Context, email *string) ([]*domain.Order, error) {
+// GetByEmail returns the orders placed with the email address within the
+// organization.
+func (s *orderRepo) GetByEmail(ctx context.Context, orgID int64, email *string) ([]*domain.Order, error) {
 	query := `
-	SELECT id, book_id, email, created_at
+	SELECT id, book_id, email, created_at, org_id
 	FROM orders
-	WHERE email = $1`
-	rows, err := s.db.Query(ctx, query, email)
+	WHERE org_id = $1 AND email = $2`
+	rows, err := s.db.Query(ctx, query, orgID, email)
 	if err != nil {
 		switch {
 		case errors.Is(err, pgx.ErrNoRows):
@@ -64,6 +70,7 @@ func (s *orderRepo) GetByEmail(ctx context.Context, email *string) ([]*domain.Or
 			&order.BookID,
 			&order.Email,
 			&order.CreatedAt,
+			&order.OrgID,
 		)
 		if err != nil {
 			return nil, err
@@ -76,3 +83,69 @@ func (s *orderRepo) GetByEmail(ctx context.Context, email *string) ([]*domain.Or
 
 	return orders, nil
 }
+
+// GetAllByEmail returns the orders placed with the email address in any
+// organization; it serves privacy requests, which follow the person rather
+// than the tenant.
+func (s *orderRepo) GetAllByEmail(ctx context.Context, email string) ([]*domain.Order, error) {
+	query := `
+	SELECT id, book_id, email, created_at, org_id
+	FROM orders
+	WHERE email = $1
+	ORDER BY id`
+	rows, err := s.db.Query(ctx, query, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []*domain.Order{}
+	for rows.Next() {
+		var order domain.Order
+		err := rows.Scan(
+			&order.ID,
+			&order.BookID,
+			&order.Email,
+			&order.CreatedAt,
+			&order.OrgID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, &order)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (s *orderRepo) ReplaceEmail(ctx context.Context, email, replacement string) (int64, error) {
+	query := `
+	UPDATE orders
+	SET email = $2
+	WHERE email = $1`
+
+	result, err := s.db.Exec(ctx, query, email, replacement)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// AssignOrganization moves the orders that have no organization, and were
+// placed with email unless it is nil, to orgID. Orders that already belong
+// to an organization are never moved.
+func (s *orderRepo) AssignOrganization(ctx context.Context, orgID int64, email *string) (int64, error) {
+	query := `
+	UPDATE orders
+	SET org_id = $1
+	WHERE org_id IS NULL AND ($2::text IS NULL OR email = $2)`
+
+	result, err := s.db.Exec(ctx, query, orgID, email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
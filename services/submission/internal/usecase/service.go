@@ -2,10 +2,13 @@ package usecase
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"microservices/pkg/validator"
 	"microservices/services/submission/internal/domain"
 	"microservices/services/submission/internal/repository"
+	"strings"
 )
 
 var (
@@ -15,11 +18,23 @@ var (
 type CreateOrderDTO struct {
 	BookID int64  `json:"book_id"`
 	Email  string `json:"email"`
+	// OrgID is the organization placing the order, taken from the token.
+	OrgID int64 `json:"-"`
+}
+
+// AssignOrganizationDTO assigns orders placed before organizations existed.
+// Email, when set, limits it to the orders placed with that address.
+type AssignOrganizationDTO struct {
+	OrgID int64   `json:"org_id"`
+	Email *string `json:"email"`
 }
 
 type OrderService interface {
 	Create(ctx context.Context, order CreateOrderDTO) error
-	Show(ctx context.Context, email string) ([]*domain.Order, error)
+	Show(ctx context.Context, orgID int64, email string) ([]*domain.Order, error)
+	ExportUserData(ctx context.Context, email string) ([]*domain.Order, error)
+	EraseUserData(ctx context.Context, email string) (int64, error)
+	AssignOrganization(ctx context.Context, input AssignOrganizationDTO) (int64, error)
 }
 
 type service struct {
@@ -36,8 +51,10 @@ func (s *service) Create(ctx context.Context, input CreateOrderDTO) error {
 	order := domain.Order{
 		BookID: input.BookID,
 		Email:  input.Email,
+		OrgID:  input.OrgID,
 	}
 	v := validator.New()
+	v.Check(order.OrgID > 0, "org_id", "must be provided")
 	validateEmail(v, order.Email)
 	if !v.Valid() {
 		return ErrFailedValidation
@@ -51,14 +68,14 @@ func (s *service) Create(ctx context.Context, input CreateOrderDTO) error {
 
 }
 
-func (s *service) Show(ctx context.Context, email string) ([]*domain.Order, error) {
+func (s *service) Show(ctx context.Context, orgID int64, email string) ([]*domain.Order, error) {
 	v := validator.New()
 	validateEmail(v, email)
 	if !v.Valid() {
 		return nil, ErrFailedValidation
 	}
 
-	orders, err := s.repo.GetByEmail(ctx, &email)
+	orders, err := s.repo.GetByEmail(ctx, orgID, &email)
 	if err != nil {
 		switch {
 		case errors.Is(err, repository.ErrRecordNotFound):
@@ -70,6 +87,50 @@ func (s *service) Show(ctx context.Context, email string) ([]*domain.Order, erro
 	return orders, nil
 }
 
+// ExportUserData returns the orders placed with the email address.
+func (s *service) ExportUserData(ctx context.Context, email string) ([]*domain.Order, error) {
+	v := validator.New()
+	validateEmail(v, email)
+	if !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	return s.repo.GetAllByEmail(ctx, email)
+}
+
+// EraseUserData replaces the email address on the user's orders with a
+// pseudonym. The same address always maps to the same pseudonym, so the
+// orders of one person stay grouped without naming them.
+func (s *service) EraseUserData(ctx context.Context, email string) (int64, error) {
+	v := validator.New()
+	validateEmail(v, email)
+	if !v.Valid() {
+		return 0, ErrFailedValidation
+	}
+
+	return s.repo.ReplaceEmail(ctx, email, pseudonymiseEmail(email))
+}
+
+// AssignOrganization moves orders without an organization to input.OrgID
+// and returns how many were moved.
+func (s *service) AssignOrganization(ctx context.Context, input AssignOrganizationDTO) (int64, error) {
+	v := validator.New()
+	v.Check(input.OrgID > 0, "org_id", "must be provided")
+	if input.Email != nil {
+		validateEmail(v, *input.Email)
+	}
+	if !v.Valid() {
+		return 0, ErrFailedValidation
+	}
+
+	return s.repo.AssignOrganization(ctx, input.OrgID, input.Email)
+}
+
+func pseudonymiseEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return "erased-" + hex.EncodeToString(sum[:8]) + "@erased.invalid"
+}
+
 func validateEmail(v *validator.Validator, email string) {
 	v.Check(email != "", "email", "must be provided")
 	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be a valid email address")
@@ -1,26 +1,65 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"flag"
 	"log"
 	"microservices/pkg/hash"
+	"microservices/pkg/mailer"
+	"microservices/pkg/oidc"
+	"microservices/pkg/privacy"
 	"microservices/pkg/store/postgres"
 	"microservices/pkg/token"
+	"microservices/pkg/totp"
+	"microservices/pkg/validator"
+	"microservices/services/user/internal/delivery/grpc"
 	"microservices/services/user/internal/delivery/http"
 	"microservices/services/user/internal/repository"
 	"microservices/services/user/internal/usecase"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
 	dbConnCfg := postgres.ConnConfig{}
 	httpServerCfg := http.ServerConfig{}
+	grpcServerCfg := grpc.ServerConfig{}
+	routerCfg := http.RouterConfig{IntrospectionKey: os.Getenv("INTROSPECTION_KEY")}
+
+	userCfg := usecase.Config{}
+	smtpCfg := mailer.SMTPConfig{}
+	oidcCfg := oidc.Config{ClientSecret: os.Getenv("OIDC_CLIENT_SECRET")}
+	tokenCfg := token.Config{HMACSecret: os.Getenv("TOKEN_KEY")}
+
+	var (
+		passwordHasher         string
+		bcryptCost             int
+		mailDir                string
+		mfaRequiredPermissions string
+		oidcProvider           string
+		oidcScopes             string
+		breachedPasswordsDir   string
+		breachedPasswordsMin   int
+		minPasswordStrength    int
+		contractServiceURL     string
+		submissionServiceURL   string
+		bootstrapAdmin         string
+	)
 
 	flag.IntVar(&httpServerCfg.Port, "http-port", 4000, "HTTP server port")
 	flag.StringVar(&httpServerCfg.ReadTimeout, "http-read-timeout", "10s", "HTTP read timeout")
 	flag.StringVar(&httpServerCfg.WriteTimeout, "http-write-timeout", "30s", "HTTP write timeout")
 	flag.StringVar(&httpServerCfg.IdleTimeout, "http-idle-timeout", "1m", "HTTP idle timeout")
 
+	flag.Float64Var(&routerCfg.IntrospectionRPS, "introspection-rps", 50, "API key introspection requests per second allowed from one service instance, which asks once per key and cache period")
+	flag.IntVar(&routerCfg.IntrospectionBurst, "introspection-burst", 100, "API key introspection burst allowed from one service instance")
+
+	flag.IntVar(&grpcServerCfg.Port, "grpc-port", 5000, "gRPC server port")
+
 	flag.IntVar(&dbConnCfg.Port, "pg-port", 5432, "Postgres port")
 	flag.StringVar(&dbConnCfg.Host, "pg-host", "localhost", "Postgres host")
 	flag.StringVar(&dbConnCfg.User, "pg-user", os.Getenv("POSTGRE_USER"), "Postgres user")
@@ -28,8 +67,97 @@ func main() {
 	flag.StringVar(&dbConnCfg.DbName, "pg-db-name", os.Getenv("POSTGRE_DB_NAME"), "Postgres DB name")
 	flag.IntVar(&dbConnCfg.MaxOpenConns, "pg-max-open-conns", 15, "Postgres max open connections")
 	flag.StringVar(&dbConnCfg.MaxIdleTime, "pg-max-idle-time", "15m", "Postgres max connection idle time")
+
+	flag.StringVar(&tokenCfg.Issuer, "token-issuer", "microservices/user", "JWT issuer")
+	flag.StringVar(&tokenCfg.Audience, "token-audience", "microservices", "JWT audience")
+	flag.StringVar(&tokenCfg.SigningKeyID, "token-signing-key-id", "", "JWT signing key ID (kid)")
+	flag.StringVar(&tokenCfg.SigningKeyFile, "token-signing-key", "", "PEM file with the RSA or Ed25519 JWT signing key")
+	flag.StringVar(&tokenCfg.VerificationKeys, "token-verification-keys", "", "Comma-separated kid=path list of PEM public keys accepted for JWT verification")
+
+	flag.StringVar(&bootstrapAdmin, "bootstrap-admin", "", "Email address of a verified account to give the admin role at startup, to set up a new installation")
+
+	flag.StringVar(&passwordHasher, "password-hasher", "bcrypt", "Password hashing algorithm (bcrypt|argon2id)")
+	flag.IntVar(&bcryptCost, "bcrypt-cost", 12, "bcrypt cost factor")
+
+	flag.DurationVar(&userCfg.AccessTokenTTL, "access-token-ttl", 15*time.Minute, "Access token lifetime")
+	flag.DurationVar(&userCfg.RefreshTokenTTL, "refresh-token-ttl", 30*24*time.Hour, "Refresh token lifetime")
+	flag.DurationVar(&userCfg.VerificationTokenTTL, "verification-token-ttl", 24*time.Hour, "Email verification token lifetime")
+	flag.DurationVar(&userCfg.MailResendInterval, "mail-resend-interval", time.Minute, "Minimum interval between verification or reset emails to one account")
+	flag.BoolVar(&userCfg.RequireVerifiedEmail, "require-verified-email", false, "Block sign-in until the email address is verified")
+	flag.DurationVar(&userCfg.PasswordResetTokenTTL, "password-reset-token-ttl", 45*time.Minute, "Password reset token lifetime")
+
+	flag.IntVar(&minPasswordStrength, "min-password-strength", 0, "Minimum password strength score from 0 (anything) to 4")
+	flag.StringVar(&breachedPasswordsDir, "breached-passwords-dir", "", "Directory of SHA-1 prefix files listing breached passwords; the check is skipped when empty")
+	flag.IntVar(&breachedPasswordsMin, "breached-passwords-min-count", 1, "Times a password must appear in the breach corpus to be rejected")
+
+	flag.DurationVar(&userCfg.MagicLinkTTL, "magic-link-ttl", 15*time.Minute, "Magic sign-in link lifetime")
+	flag.StringVar(&userCfg.MagicLinkURL, "magic-link-url", "", "Page magic sign-in links point to; the email contains the bare token when empty")
+	flag.BoolVar(&userCfg.SignUpDisabled, "disable-signup", false, "Turn off open sign-up, including first sign-in through an identity provider; new users then join by invitation")
+	flag.DurationVar(&userCfg.InvitationTTL, "invitation-ttl", 7*24*time.Hour, "Organization invitation lifetime")
+	flag.StringVar(&userCfg.InvitationURL, "invitation-url", "", "Page invitation emails point to; the email contains the bare token when empty")
+
+	flag.IntVar(&userCfg.MaxFailedSignIns, "max-failed-signins", 5, "Failed sign-ins for one email before it is locked")
+	flag.IntVar(&userCfg.MaxFailedSignInsPerIP, "max-failed-signins-per-ip", 50, "Failed sign-ins from one IP before it is locked")
+	flag.DurationVar(&userCfg.LockoutDuration, "lockout-duration", time.Minute, "Initial lockout, doubled for every further failure")
+	flag.DurationVar(&userCfg.MaxLockoutDuration, "max-lockout-duration", time.Hour, "Maximum lockout duration")
+	flag.DurationVar(&userCfg.AccountUnlockTokenTTL, "account-unlock-token-ttl", 24*time.Hour, "Account unlock token lifetime")
+
+	flag.StringVar(&userCfg.MFAIssuer, "mfa-issuer", "Microservices", "Issuer shown in authenticator apps")
+	flag.DurationVar(&userCfg.MFAChallengeTTL, "mfa-challenge-ttl", 5*time.Minute, "Time allowed to enter the second factor after a password sign-in")
+	flag.StringVar(&mfaRequiredPermissions, "mfa-required-permissions", "contracts:write", "Comma-separated permissions only granted to users with two-factor authentication")
+
+	flag.StringVar(&oidcProvider, "oidc-provider", "corporate", "Name of the OpenID Connect provider in /v1/user/oidc/:provider URLs")
+	flag.StringVar(&oidcCfg.Issuer, "oidc-issuer", "", "OpenID Connect issuer URL; external sign-in is disabled when empty")
+	flag.StringVar(&oidcCfg.ClientID, "oidc-client-id", "", "OpenID Connect client ID")
+	flag.StringVar(&oidcCfg.RedirectURL, "oidc-redirect-url", "http://localhost:4000/v1/user/oidc/corporate/callback", "OpenID Connect redirect URL registered with the provider")
+	flag.StringVar(&oidcScopes, "oidc-scopes", "openid,email,profile", "Comma-separated OpenID Connect scopes")
+	flag.DurationVar(&userCfg.OIDCStateTTL, "oidc-state-ttl", 10*time.Minute, "Time allowed to complete sign-in at the identity provider")
+
+	flag.StringVar(&contractServiceURL, "contract-service-url", "http://localhost:4040", "Base URL of the contract service, included in privacy exports and erasures; skipped when empty")
+	flag.StringVar(&submissionServiceURL, "submission-service-url", "http://localhost:8080", "Base URL of the submission service, included in privacy exports and erasures; skipped when empty")
+	flag.DurationVar(&userCfg.PrivacyPollInterval, "privacy-poll-interval", 10*time.Second, "How often queued privacy requests are picked up")
+	flag.DurationVar(&userCfg.PrivacyArchiveTTL, "privacy-archive-ttl", 7*24*time.Hour, "How long data export archives can be downloaded before they are deleted")
+
+	flag.StringVar(&smtpCfg.Host, "smtp-host", "", "SMTP host; mail is written to -mail-dir when empty")
+	flag.IntVar(&smtpCfg.Port, "smtp-port", 587, "SMTP port")
+	flag.StringVar(&smtpCfg.Username, "smtp-username", os.Getenv("SMTP_USERNAME"), "SMTP username")
+	flag.StringVar(&smtpCfg.Password, "smtp-password", os.Getenv("SMTP_PASSWORD"), "SMTP password")
+	flag.StringVar(&smtpCfg.Sender, "smtp-sender", "Microservices <no-reply@microservices.local>", "SMTP sender")
+	flag.StringVar(&mailDir, "mail-dir", "", "Directory for outgoing mail when SMTP is not configured; logged when empty")
 	flag.Parse()
 
+	mfaSecretKey, err := base64.StdEncoding.DecodeString(os.Getenv("MFA_SECRET_KEY"))
+	if err != nil {
+		log.Fatalf("MFA_SECRET_KEY: %v", err)
+	}
+	userCfg.MFASecrets, err = totp.NewSecretBox(mfaSecretKey)
+	if err != nil {
+		log.Fatalf("MFA_SECRET_KEY must be a base64 encoded %d byte key: %v", totp.KeySize, err)
+	}
+
+	if routerCfg.IntrospectionKey == "" {
+		log.Print("INTROSPECTION_KEY is not set, other services cannot resolve API keys")
+	}
+
+	if mfaRequiredPermissions != "" {
+		userCfg.MFARequiredPermissions = strings.Split(mfaRequiredPermissions, ",")
+	}
+
+	passwordRules := []validator.PasswordRule{validator.NoUserInputs(), validator.MinStrength(minPasswordStrength)}
+	if breachedPasswordsDir != "" {
+		breached, err := validator.NewBreachedPasswords(breachedPasswordsDir, breachedPasswordsMin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		passwordRules = append(passwordRules, validator.NotBreached(breached))
+	}
+	userCfg.PasswordPolicy = validator.NewPasswordPolicy(passwordRules...)
+
+	if oidcCfg.Issuer != "" {
+		oidcCfg.Scopes = strings.Split(oidcScopes, ",")
+		userCfg.OIDCProviders = map[string]usecase.OIDCProvider{oidcProvider: oidc.NewProvider(oidcCfg)}
+	}
+
 	db, err := postgres.OpenDB(dbConnCfg)
 	if err != nil {
 		log.Fatal(err)
@@ -38,16 +166,86 @@ func main() {
 
 	log.Print("database connection pool established")
 
-	passwordsHashCost := hash.NewBCryptHasher(12)
-	tokenManager, err := token.NewManager(os.Getenv("TOKEN_KEY"))
-	userRepository := repository.NewUserRepo(db.Pool)
-	userService := usecase.New(userRepository, passwordsHashCost, tokenManager)
+	bcryptHasher := hash.NewBCryptHasher(bcryptCost)
+	argon2idHasher := hash.NewArgon2idHasher(hash.DefaultArgon2idParams)
+
+	var hasher hash.PasswordHasher
+	switch passwordHasher {
+	case "bcrypt":
+		hasher = hash.NewUpgradingHasher(bcryptHasher, argon2idHasher)
+	case "argon2id":
+		hasher = hash.NewUpgradingHasher(argon2idHasher, bcryptHasher)
+	default:
+		log.Fatalf("unknown password hasher %q", passwordHasher)
+	}
+
+	tokenManager, err := token.NewManagerFromConfig(tokenCfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var mail mailer.Mailer = mailer.NewFileMailer(mailDir)
+	if smtpCfg.Host != "" {
+		mail = mailer.NewSMTPMailer(smtpCfg)
+	}
 
-	httpServer := http.NewHttpServer(http.NewRouter(userService).GetRoutes(), httpServerCfg)
+	userCfg.PrivacyServices = map[string]usecase.PrivacyService{}
+	if contractServiceURL != "" {
+		userCfg.PrivacyServices["contracts"] = privacy.NewClient(strings.TrimSuffix(contractServiceURL, "/"), tokenManager)
+	}
+	if submissionServiceURL != "" {
+		userCfg.PrivacyServices["submissions"] = privacy.NewClient(strings.TrimSuffix(submissionServiceURL, "/"), tokenManager)
+	}
+
+	userService := usecase.New(repository.New(db.Pool), hasher, tokenManager, mail, userCfg)
+
+	if bootstrapAdmin != "" {
+		err = userService.BootstrapAdmin(context.Background(), bootstrapAdmin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%s has the admin role", bootstrapAdmin)
+	}
+
+	encrypted, err := userService.EncryptTOTPSecrets(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if encrypted > 0 {
+		log.Printf("encrypted %d stored TOTP secrets", encrypted)
+	}
+
+	if userCfg.PrivacyPollInterval <= 0 {
+		log.Fatal("-privacy-poll-interval must be positive")
+	}
+
+	workerCtx, stopWorkers := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopWorkers()
+
+	privacyDone := make(chan struct{})
+	go func() {
+		userService.ProcessPrivacyRequests(workerCtx)
+		close(privacyDone)
+	}()
+
+	auth := token.NewMiddleware(tokenManager).WithSessionCheck(userService)
+	grpcServer := grpc.NewGrpcServer(grpc.New(userService, grpc.Options{}), auth, grpcServerCfg)
+
+	go func() {
+		err := grpcServer.Serve()
+		if err != nil {
+			log.Fatalf("Failed to start gRPC server: %v", err)
+		}
+	}()
+
+	httpServer := http.NewHttpServer(http.NewRouter(userService, tokenManager, routerCfg).GetRoutes(), httpServerCfg)
 
 	err = httpServer.Serve()
 	if err != nil {
 		log.Fatal("Failed to start HTTP server")
 	}
 
+	<-privacyDone
+	log.Print("stopped privacy request worker")
+
 }
@@ -0,0 +1,30 @@
+package grpc
+
+import (
+	user "microservices/services/user/internal/delivery/grpc/interface"
+	"microservices/services/user/internal/usecase"
+)
+
+type Delivery struct {
+	user.UnimplementedUserServiceServer
+	ucUser usecase.UserService
+
+	options Options
+}
+
+type Options struct{}
+
+func New(ucUser usecase.UserService, o Options) *Delivery {
+	var d = &Delivery{
+		ucUser: ucUser,
+	}
+
+	d.SetOptions(o)
+	return d
+}
+
+func (d *Delivery) SetOptions(options Options) {
+	if d.options != options {
+		d.options = options
+	}
+}
@@ -0,0 +1,138 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"microservices/pkg/token"
+	user "microservices/services/user/internal/delivery/grpc/interface"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+	"microservices/services/user/internal/usecase"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (d *Delivery) GetUser(ctx context.Context, request *user.GetUserRequest) (*user.GetUserResponse, error) {
+	if err := requirePermission(ctx, "users:admin"); err != nil {
+		return nil, err
+	}
+
+	if request.GetId() < 1 {
+		return nil, status.Error(codes.InvalidArgument, "invalid id")
+	}
+
+	u, err := d.ucUser.GetUser(ctx, request.GetId())
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return &user.GetUserResponse{User: toProtoUser(u)}, nil
+}
+
+func (d *Delivery) GetUsersByIDs(ctx context.Context, request *user.GetUsersByIDsRequest) (*user.GetUsersByIDsResponse, error) {
+	if err := requirePermission(ctx, "users:admin"); err != nil {
+		return nil, err
+	}
+
+	users, err := d.ucUser.GetUsersByIDs(ctx, request.GetIds())
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return &user.GetUsersByIDsResponse{Users: toProtoUsers(users)}, nil
+}
+
+func (d *Delivery) ValidateToken(ctx context.Context, request *user.ValidateTokenRequest) (*user.ValidateTokenResponse, error) {
+	claims, err := d.ucUser.ValidateToken(ctx, request.GetAccessToken())
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+
+	return &user.ValidateTokenResponse{
+		UserId:      claims.UserID,
+		Email:       claims.Email,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+		ExpiresAt:   timestamppb.New(time.Unix(claims.ExpiresAt, 0)),
+		SessionId:   claims.SessionID,
+		OrgId:       claims.OrgID,
+		OrgRole:     claims.OrgRole,
+	}, nil
+}
+
+func (d *Delivery) ListUsers(ctx context.Context, request *user.ListUsersRequest) (*user.ListUsersResponse, error) {
+	if err := requirePermission(ctx, "users:admin"); err != nil {
+		return nil, err
+	}
+
+	filters := repository.Filters{
+		Page:         int(request.GetPage()),
+		PageSize:     int(request.GetPageSize()),
+		Sort:         request.GetSort(),
+		SortSafelist: []string{"id", "name", "email", "created_at", "-id", "-name", "-email", "-created_at"},
+	}
+	if filters.Page == 0 {
+		filters.Page = 1
+	}
+	if filters.PageSize == 0 {
+		filters.PageSize = 20
+	}
+	if filters.Sort == "" {
+		filters.Sort = "id"
+	}
+
+	users, err := d.ucUser.ListUsers(ctx, request.GetSearch(), filters)
+	if err != nil {
+		return nil, errorStatus(err)
+	}
+	return &user.ListUsersResponse{Users: toProtoUsers(users)}, nil
+}
+
+// requirePermission is the gRPC counterpart of RequirePermission on the HTTP
+// routes.
+func requirePermission(ctx context.Context, permission string) error {
+	identity, ok := token.IdentityFromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if !identity.HasPermission(permission) {
+		return status.Error(codes.PermissionDenied, "not permitted")
+	}
+	return nil
+}
+
+func errorStatus(err error) error {
+	switch {
+	case errors.Is(err, usecase.ErrFailedValidation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, usecase.ErrRecordNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, usecase.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, err.Error())
+	default:
+		log.Print(err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func toProtoUser(u *domain.User) *user.User {
+	return &user.User{
+		Id:            u.ID,
+		Name:          u.Name,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerifiedAt != nil,
+		CreatedAt:     timestamppb.New(u.CreatedAt),
+		Version:       int32(u.Version),
+	}
+}
+
+func toProtoUsers(users []*domain.User) []*user.User {
+	result := make([]*user.User, 0, len(users))
+	for _, u := range users {
+		result = append(result, toProtoUser(u))
+	}
+	return result
+}
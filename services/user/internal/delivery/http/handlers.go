@@ -2,28 +2,37 @@ package http
 
 import (
 	"errors"
-	"microservices-go/pkg/request"
-	"microservices-go/services/user/internal/service"
+	"fmt"
+	"microservices/pkg/request"
+	"microservices/pkg/token"
+	"microservices/pkg/validator"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+	"microservices/services/user/internal/usecase"
 	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
 )
 
 type UserHandler struct {
-	userService service.UserService
+	userService usecase.UserService
+	tokens      token.TokenManager
 }
 
-func NewHandler(service service.UserService) *UserHandler {
-	return &UserHandler{userService: service}
+func NewHandler(service usecase.UserService, tokens token.TokenManager) *UserHandler {
+	return &UserHandler{userService: service, tokens: tokens}
 }
 
 func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
-	var dto service.UserSignUpDTO
+	var dto usecase.UserSignUpDTO
 
 	if err := request.ReadJSON(w, r, &dto); err != nil {
 		request.BadRequestResponse(w, r, err)
 		return
 	}
 
-	input := service.UserSignUpDTO{
+	input := usecase.UserSignUpDTO{
 		Name:         dto.Name,
 		Email:        dto.Email,
 		HashPassword: dto.HashPassword,
@@ -31,13 +40,20 @@ func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 
 	err := h.userService.SignUp(r.Context(), input)
 	if err != nil {
+		var rejected usecase.PasswordRejectedError
 		switch {
-		case errors.Is(err, service.ErrFailedValidation):
+		case errors.As(err, &rejected):
+			request.FailedValidationResponse(w, r, map[string]string{"password": rejected.Reason})
+			return
+		case errors.Is(err, usecase.ErrFailedValidation):
 			request.BadRequestResponse(w, r, err)
 			return
-		case errors.Is(err, service.ErrDuplicate):
+		case errors.Is(err, usecase.ErrDuplicate):
 			request.RecordDuplicationResponse(w, r)
 			return
+		case errors.Is(err, usecase.ErrSignUpDisabled):
+			request.ErrorResponse(w, r, http.StatusForbidden, err.Error())
+			return
 		default:
 			request.ServerErrorResponse(w, r, err)
 			return
@@ -49,7 +65,7 @@ func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 
 func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 
-	var input service.UserSignInDTO
+	var input usecase.UserSignInDTO
 
 	if err := request.ReadJSON(w, r, &input); err != nil {
 		request.BadRequestResponse(w, r, err)
@@ -60,17 +76,1303 @@ func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	//	Email:        dto.Email,
 	//	HashPassword: dto.HashPassword,
 	//}
+	input.IP = request.ClientIP(r)
+	input.UserAgent = r.UserAgent()
+
+	tokens, err := h.userService.SignIn(r.Context(), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrWrongCredentials):
+			request.NotFoundResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrAccountDisabled):
+			request.AccountDisabledResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrPasswordResetRequired):
+			request.PasswordResetRequiredResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrEmailNotVerified):
+			request.UnverifiedAccountResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrRateLimited):
+			request.RateLimitExceededResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	request.WriteJSON(w, http.StatusOK, tokens, nil)
+}
+
+func (h *UserHandler) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var input usecase.EmailDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err := h.userService.RequestMagicLink(r.Context(), input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *UserHandler) SignInWithMagicLink(w http.ResponseWriter, r *http.Request) {
+	var input usecase.MagicLinkSignInDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+	input.IP = request.ClientIP(r)
+	input.UserAgent = r.UserAgent()
+
+	tokens, err := h.userService.SignInWithMagicLink(r.Context(), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidToken):
+			request.InvalidAuthenticationTokenResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrAccountDisabled):
+			request.AccountDisabledResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrPasswordResetRequired):
+			request.PasswordResetRequiredResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	request.WriteJSON(w, http.StatusOK, tokens, nil)
+}
+
+func (h *UserHandler) BeginOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider := httprouter.ParamsFromContext(r.Context()).ByName("provider")
+
+	authURL, err := h.userService.BeginOIDCLogin(r.Context(), provider)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrUnknownProvider):
+			request.NotFoundResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (h *UserHandler) CompleteOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	input := usecase.OIDCCallbackDTO{
+		Provider:  httprouter.ParamsFromContext(r.Context()).ByName("provider"),
+		Code:      qs.Get("code"),
+		State:     qs.Get("state"),
+		Error:     qs.Get("error"),
+		IP:        request.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
 
-	token, err := h.userService.SignIn(r.Context(), input)
+	tokens, err := h.userService.CompleteOIDCLogin(r.Context(), input)
 	if err != nil {
 		switch {
-		case errors.Is(err, service.ErrWrongCredentials):
+		case errors.Is(err, usecase.ErrUnknownProvider):
 			request.NotFoundResponse(w, r)
 			return
+		case errors.Is(err, usecase.ErrInvalidToken), errors.Is(err, usecase.ErrExternalAuthFailed):
+			request.InvalidAuthenticationTokenResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrEmailNotVerified):
+			request.UnverifiedAccountResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrAccountDisabled):
+			request.AccountDisabledResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrDuplicate):
+			request.RecordDuplicationResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrSignUpDisabled):
+			request.ErrorResponse(w, r, http.StatusForbidden, err.Error())
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	request.WriteJSON(w, http.StatusOK, tokens, nil)
+}
+
+func (h *UserHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var input usecase.RefreshTokenDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	tokens, err := h.userService.Refresh(r.Context(), input.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidRefreshToken):
+			request.InvalidAuthenticationTokenResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrAccountDisabled):
+			request.AccountDisabledResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	request.WriteJSON(w, http.StatusOK, tokens, nil)
+}
+
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var input usecase.RefreshTokenDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err := h.userService.Logout(r.Context(), input.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidRefreshToken):
+			request.InvalidAuthenticationTokenResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	err := h.userService.LogoutAll(r.Context(), userID)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var input usecase.VerifyEmailDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err := h.userService.VerifyEmail(r.Context(), input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidToken):
+			request.FailedValidationResponse(w, r, map[string]string{"token": "invalid or expired verification token"})
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var input usecase.EmailDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err := h.userService.ResendVerification(r.Context(), input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var input usecase.EmailDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err := h.userService.ForgotPassword(r.Context(), input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input usecase.ResetPasswordDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err := h.userService.ResetPassword(r.Context(), input)
+	if err != nil {
+		var rejected usecase.PasswordRejectedError
+		switch {
+		case errors.As(err, &rejected):
+			request.FailedValidationResponse(w, r, map[string]string{"password": rejected.Reason})
+			return
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		case errors.Is(err, usecase.ErrInvalidToken):
+			request.FailedValidationResponse(w, r, map[string]string{"token": "invalid or expired password reset token"})
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	var input usecase.VerifyEmailDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err := h.userService.UnlockAccount(r.Context(), input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidToken):
+			request.FailedValidationResponse(w, r, map[string]string{"token": "invalid or expired unlock token"})
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) CompleteMFASignIn(w http.ResponseWriter, r *http.Request) {
+	var input usecase.MFASignInDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+	input.IP = request.ClientIP(r)
+	input.UserAgent = r.UserAgent()
+
+	tokens, err := h.userService.CompleteMFASignIn(r.Context(), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidToken):
+			request.InvalidAuthenticationTokenResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrInvalidMFACode):
+			request.FailedValidationResponse(w, r, map[string]string{"code": err.Error()})
+			return
+		case errors.Is(err, usecase.ErrAccountDisabled):
+			request.AccountDisabledResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrRateLimited):
+			request.RateLimitExceededResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	request.WriteJSON(w, http.StatusOK, tokens, nil)
+}
+
+func (h *UserHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	enrollment, err := h.userService.EnrollTOTP(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrMFAAlreadyEnabled):
+			request.ErrorResponse(w, r, http.StatusConflict, err.Error())
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	request.WriteJSON(w, http.StatusCreated, enrollment, nil)
+}
+
+func (h *UserHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	var input usecase.MFACodeDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	codes, err := h.userService.ConfirmTOTP(r.Context(), userID, input.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrMFAAlreadyEnabled):
+			request.ErrorResponse(w, r, http.StatusConflict, err.Error())
+			return
+		case errors.Is(err, usecase.ErrMFANotEnabled):
+			request.BadRequestResponse(w, r, err)
+			return
+		case errors.Is(err, usecase.ErrInvalidMFACode):
+			request.FailedValidationResponse(w, r, map[string]string{"code": err.Error()})
+			return
 		default:
 			request.ServerErrorResponse(w, r, err)
 			return
 		}
 	}
-	request.WriteJSON(w, http.StatusOK, map[string]any{"token": token.PlainText}, nil)
+	request.WriteJSON(w, http.StatusOK, map[string]any{"recovery_codes": codes}, nil)
+}
+
+func (h *UserHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	var input usecase.MFACodeDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err := h.userService.DisableTOTP(r.Context(), userID, input.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrMFANotEnabled):
+			request.BadRequestResponse(w, r, err)
+			return
+		case errors.Is(err, usecase.ErrInvalidMFACode):
+			request.FailedValidationResponse(w, r, map[string]string{"code": err.Error()})
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) ShowProfile(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	user, err := h.userService.GetProfile(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"user": user}, nil)
+}
+
+func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	var input usecase.UpdateProfileDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(r.Context(), userID, input)
+	if err != nil {
+		h.profileErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"user": user}, nil)
+}
+
+func (h *UserHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	var input usecase.ChangeEmailDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err := h.userService.ChangeEmail(r.Context(), userID, input)
+	if err != nil {
+		h.profileErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	var input usecase.ChangePasswordDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err := h.userService.ChangePassword(r.Context(), userID, input)
+	if err != nil {
+		h.profileErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	var input usecase.PasswordDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err := h.userService.DeleteAccount(r.Context(), userID, input.Password)
+	if err != nil {
+		h.profileErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) profileErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var rejected usecase.PasswordRejectedError
+	switch {
+	case errors.As(err, &rejected):
+		request.FailedValidationResponse(w, r, map[string]string{"new_password": rejected.Reason})
+	case errors.Is(err, usecase.ErrFailedValidation):
+		request.BadRequestResponse(w, r, err)
+	case errors.Is(err, usecase.ErrWrongCredentials):
+		request.FailedValidationResponse(w, r, map[string]string{"password": "incorrect password"})
+	case errors.Is(err, usecase.ErrDuplicate):
+		request.RecordDuplicationResponse(w, r)
+	case errors.Is(err, usecase.ErrEditConflict):
+		request.EditConflictResponse(w, r)
+	case errors.Is(err, usecase.ErrRecordNotFound):
+		request.NotFoundResponse(w, r)
+	default:
+		request.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (h *UserHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.userService.ListRoles(r.Context())
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"roles": roles}, nil)
+}
+
+func (h *UserHandler) ShowUserRoles(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	roles, err := h.userService.GetUserRoles(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"roles": roles}, nil)
+}
+
+func (h *UserHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	var input usecase.AssignRoleDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	actorID, _ := token.UserIDFromContext(r.Context())
+
+	err = h.userService.AssignRole(r.Context(), actorID, id, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) RevokeRole(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	role := httprouter.ParamsFromContext(r.Context()).ByName("role")
+
+	actorID, _ := token.UserIDFromContext(r.Context())
+
+	err = h.userService.RevokeRole(r.Context(), actorID, id, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	identity, _ := token.IdentityFromContext(r.Context())
+
+	sessions, err := h.userService.ListSessions(r.Context(), identity.UserID, identity.SessionID)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"sessions": sessions}, nil)
+}
+
+func (h *UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	err = h.userService.RevokeSession(r.Context(), userID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CheckSession answers 204 while the session of the bearer token is active.
+// The middleware already rejected revoked sessions with 401; other services
+// use it to learn about revocations.
+func (h *UserHandler) CheckSession(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	identity, _ := token.IdentityFromContext(r.Context())
+
+	var input usecase.CreateAPIKeyDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+	input.OrgID = identity.OrgID
+
+	plaintext, key, err := h.userService.CreateAPIKey(r.Context(), identity.UserID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	request.WriteJSON(w, http.StatusCreated, map[string]any{"api_key": plaintext, "key": key}, nil)
+}
+
+func (h *UserHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	keys, err := h.userService.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"keys": keys}, nil)
+}
+
+func (h *UserHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	err = h.userService.RevokeAPIKey(r.Context(), userID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrRecordNotFound):
+			request.NotFoundResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// IntrospectAPIKey lets other services resolve an API key presented to them.
+// Only services holding the introspection key may call it.
+func (h *UserHandler) IntrospectAPIKey(w http.ResponseWriter, r *http.Request) {
+	var input token.APIKeyIntrospection
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	identity, err := h.userService.ResolveAPIKey(r.Context(), input.APIKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrInvalidToken):
+			request.InvalidAuthenticationTokenResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	request.WriteJSON(w, http.StatusOK, token.APIKeyIntrospection{
+		UserID:      identity.UserID,
+		Email:       identity.Email,
+		Roles:       identity.Roles,
+		Permissions: identity.Permissions,
+		OrgID:       identity.OrgID,
+		OrgRole:     identity.OrgRole,
+	}, nil)
+}
+
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Search string
+		repository.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Search = request.ReadString(qs, "search", "")
+
+	input.Filters.Page = request.ReadInt(qs, "page", 1, v)
+	input.Filters.PageSize = request.ReadInt(qs, "page_size", 20, v)
+	input.Filters.Sort = request.ReadString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "name", "email", "created_at", "-id", "-name", "-email", "-created_at"}
+
+	if !v.Valid() {
+		request.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	users, err := h.userService.ListUsers(r.Context(), input.Search, input.Filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"users": users}, nil)
+}
+
+func (h *UserHandler) ShowUser(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	user, err := h.userService.GetUser(r.Context(), id)
+	if err != nil {
+		h.adminErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"user": user}, nil)
+}
+
+func (h *UserHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	actorID, _ := token.UserIDFromContext(r.Context())
+
+	err = h.userService.DisableUser(r.Context(), actorID, id)
+	if err != nil {
+		h.adminErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	actorID, _ := token.UserIDFromContext(r.Context())
+
+	err = h.userService.EnableUser(r.Context(), actorID, id)
+	if err != nil {
+		h.adminErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	actorID, _ := token.UserIDFromContext(r.Context())
+
+	err = h.userService.ForcePasswordReset(r.Context(), actorID, id)
+	if err != nil {
+		h.adminErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) ShowAuditLog(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	var filters repository.Filters
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters.Page = request.ReadInt(qs, "page", 1, v)
+	filters.PageSize = request.ReadInt(qs, "page_size", 20, v)
+	filters.Sort = "-created_at"
+	filters.SortSafelist = []string{"-created_at"}
+
+	if !v.Valid() {
+		request.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, err := h.userService.GetAuditLog(r.Context(), id, filters)
+	if err != nil {
+		h.adminErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"events": events}, nil)
+}
+
+func (h *UserHandler) adminErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrFailedValidation):
+		request.BadRequestResponse(w, r, err)
+	case errors.Is(err, usecase.ErrRecordNotFound):
+		request.NotFoundResponse(w, r)
+	default:
+		request.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (h *UserHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	var input usecase.CreateOrganizationDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	org, err := h.userService.CreateOrganization(r.Context(), userID, input)
+	if err != nil {
+		h.orgErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusCreated, map[string]any{"organization": org}, nil)
+}
+
+func (h *UserHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	orgs, err := h.userService.ListOrganizations(r.Context(), userID)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"organizations": orgs}, nil)
+}
+
+func (h *UserHandler) ShowOrganization(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	org, err := h.userService.GetOrganization(r.Context(), userID, id)
+	if err != nil {
+		h.orgErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"organization": org}, nil)
+}
+
+func (h *UserHandler) SwitchOrganization(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	identity, _ := token.IdentityFromContext(r.Context())
+
+	tokens, err := h.userService.SwitchOrganization(r.Context(), identity.UserID, identity.SessionID, id)
+	if err != nil {
+		h.orgErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, tokens, nil)
+}
+
+func (h *UserHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	members, err := h.userService.ListMembers(r.Context(), userID, id)
+	if err != nil {
+		h.orgErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"members": members}, nil)
+}
+
+func (h *UserHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	id, userID, ok := readMemberParams(w, r)
+	if !ok {
+		return
+	}
+
+	var input usecase.UpdateMemberDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	actorID, _ := token.UserIDFromContext(r.Context())
+
+	err := h.userService.UpdateMemberRole(r.Context(), actorID, id, userID, input.Role)
+	if err != nil {
+		h.orgErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	id, userID, ok := readMemberParams(w, r)
+	if !ok {
+		return
+	}
+
+	actorID, _ := token.UserIDFromContext(r.Context())
+
+	err := h.userService.RemoveMember(r.Context(), actorID, id, userID)
+	if err != nil {
+		h.orgErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) InviteMember(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	var input usecase.InviteMemberDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	actorID, _ := token.UserIDFromContext(r.Context())
+
+	invitation, err := h.userService.InviteMember(r.Context(), actorID, id, input)
+	if err != nil {
+		h.orgErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusCreated, map[string]any{"invitation": invitation}, nil)
+}
+
+func (h *UserHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	actorID, _ := token.UserIDFromContext(r.Context())
+
+	invitations, err := h.userService.ListInvitations(r.Context(), actorID, id)
+	if err != nil {
+		h.orgErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"invitations": invitations}, nil)
+}
+
+func (h *UserHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	invitationID, err := strconv.ParseInt(httprouter.ParamsFromContext(r.Context()).ByName("invitation"), 10, 64)
+	if err != nil || invitationID < 1 {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	actorID, _ := token.UserIDFromContext(r.Context())
+
+	err = h.userService.RevokeInvitation(r.Context(), actorID, id, invitationID)
+	if err != nil {
+		h.orgErrorResponse(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UserHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var input usecase.AcceptInvitationDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	org, err := h.userService.AcceptInvitation(r.Context(), input)
+	if err != nil {
+		var rejected usecase.PasswordRejectedError
+		switch {
+		case errors.As(err, &rejected):
+			request.FailedValidationResponse(w, r, map[string]string{"password": rejected.Reason})
+			return
+		case errors.Is(err, usecase.ErrFailedValidation):
+			request.BadRequestResponse(w, r, err)
+			return
+		case errors.Is(err, usecase.ErrInvalidToken):
+			request.FailedValidationResponse(w, r, map[string]string{"token": "invalid or expired invitation token"})
+			return
+		case errors.Is(err, usecase.ErrAccountDisabled):
+			request.AccountDisabledResponse(w, r)
+			return
+		case errors.Is(err, usecase.ErrDuplicate):
+			request.RecordDuplicationResponse(w, r)
+			return
+		default:
+			request.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"organization": org}, nil)
+}
+
+// readMemberParams reads the organization and user IDs of
+// /v1/orgs/:id/members/:user.
+func readMemberParams(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return 0, 0, false
+	}
+
+	userID, err := strconv.ParseInt(httprouter.ParamsFromContext(r.Context()).ByName("user"), 10, 64)
+	if err != nil || userID < 1 {
+		request.NotFoundResponse(w, r)
+		return 0, 0, false
+	}
+	return id, userID, true
+}
+
+func (h *UserHandler) orgErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, usecase.ErrFailedValidation):
+		request.BadRequestResponse(w, r, err)
+	case errors.Is(err, usecase.ErrRecordNotFound):
+		request.NotFoundResponse(w, r)
+	case errors.Is(err, usecase.ErrNotPermitted):
+		request.NotPermittedResponse(w, r)
+	case errors.Is(err, usecase.ErrLastOwner):
+		request.FailedValidationResponse(w, r, map[string]string{"role": err.Error()})
+	case errors.Is(err, usecase.ErrDuplicate):
+		request.RecordDuplicationResponse(w, r)
+	case errors.Is(err, usecase.ErrInvalidToken):
+		request.InvalidAuthenticationTokenResponse(w, r)
+	default:
+		request.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (h *UserHandler) RequestDataExport(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	privacyRequest, err := h.userService.RequestDataExport(r.Context(), userID, userID)
+	if err != nil {
+		h.profileErrorResponse(w, r, err)
+		return
+	}
+	writePrivacyRequest(w, r, privacyRequest, "/v1/user/privacy/requests/%d")
+}
+
+func (h *UserHandler) RequestErasure(w http.ResponseWriter, r *http.Request) {
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	var input usecase.PasswordDTO
+
+	if err := request.ReadJSON(w, r, &input); err != nil {
+		request.BadRequestResponse(w, r, err)
+		return
+	}
+
+	privacyRequest, err := h.userService.RequestOwnErasure(r.Context(), userID, input.Password)
+	if err != nil {
+		h.profileErrorResponse(w, r, err)
+		return
+	}
+	writePrivacyRequest(w, r, privacyRequest, "/v1/user/privacy/requests/%d")
+}
+
+func (h *UserHandler) ShowOwnPrivacyRequest(w http.ResponseWriter, r *http.Request) {
+	privacyRequest, ok := h.ownPrivacyRequest(w, r)
+	if !ok {
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"privacy_request": privacyRequest}, nil)
+}
+
+func (h *UserHandler) DownloadOwnPrivacyArchive(w http.ResponseWriter, r *http.Request) {
+	privacyRequest, ok := h.ownPrivacyRequest(w, r)
+	if !ok {
+		return
+	}
+	h.writePrivacyArchive(w, r, privacyRequest.ID)
+}
+
+// ownPrivacyRequest loads the request named in the URL, answering 404 when
+// it belongs to another user.
+func (h *UserHandler) ownPrivacyRequest(w http.ResponseWriter, r *http.Request) (*domain.PrivacyRequest, bool) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return nil, false
+	}
+
+	userID, _ := token.UserIDFromContext(r.Context())
+
+	privacyRequest, err := h.userService.GetPrivacyRequest(r.Context(), id)
+	if err != nil {
+		h.adminErrorResponse(w, r, err)
+		return nil, false
+	}
+	if privacyRequest.UserID != userID {
+		request.NotFoundResponse(w, r)
+		return nil, false
+	}
+	return privacyRequest, true
+}
+
+func (h *UserHandler) AdminRequestDataExport(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	actorID, _ := token.UserIDFromContext(r.Context())
+
+	privacyRequest, err := h.userService.RequestDataExport(r.Context(), actorID, id)
+	if err != nil {
+		h.adminErrorResponse(w, r, err)
+		return
+	}
+	writePrivacyRequest(w, r, privacyRequest, "/v1/privacy/requests/%d")
+}
+
+func (h *UserHandler) AdminRequestErasure(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	actorID, _ := token.UserIDFromContext(r.Context())
+
+	privacyRequest, err := h.userService.RequestErasure(r.Context(), actorID, id)
+	if err != nil {
+		h.adminErrorResponse(w, r, err)
+		return
+	}
+	writePrivacyRequest(w, r, privacyRequest, "/v1/privacy/requests/%d")
+}
+
+func (h *UserHandler) ShowPrivacyRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+
+	privacyRequest, err := h.userService.GetPrivacyRequest(r.Context(), id)
+	if err != nil {
+		h.adminErrorResponse(w, r, err)
+		return
+	}
+	request.WriteJSON(w, http.StatusOK, map[string]any{"privacy_request": privacyRequest}, nil)
+}
+
+func (h *UserHandler) DownloadPrivacyArchive(w http.ResponseWriter, r *http.Request) {
+	id, err := request.ReadIDParam(r)
+	if err != nil {
+		request.NotFoundResponse(w, r)
+		return
+	}
+	h.writePrivacyArchive(w, r, id)
+}
+
+func (h *UserHandler) writePrivacyArchive(w http.ResponseWriter, r *http.Request, id int64) {
+	archive, err := h.userService.GetPrivacyArchive(r.Context(), id)
+	if err != nil {
+		h.adminErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%d.json"`, id))
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+// writePrivacyRequest answers 202 Accepted with the queued request and a
+// Location header pointing at its status resource.
+func writePrivacyRequest(w http.ResponseWriter, r *http.Request, privacyRequest *domain.PrivacyRequest, location string) {
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf(location, privacyRequest.ID))
+
+	err := request.WriteJSON(w, http.StatusAccepted, map[string]any{"privacy_request": privacyRequest}, headers)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (h *UserHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "public, max-age=300")
+
+	err := request.WriteJSON(w, http.StatusOK, h.tokens.JWKS(), headers)
+	if err != nil {
+		request.ServerErrorResponse(w, r, err)
+	}
 }
@@ -1,26 +1,115 @@
 package http
 
 import (
-	"microservices-go/services/user/internal/service"
+	"microservices/pkg/request"
+	"microservices/pkg/token"
+	"microservices/services/user/internal/usecase"
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
 )
 
 type router struct {
-	user UserHandler
+	user   UserHandler
+	auth   *token.Middleware
+	config RouterConfig
+
+	introspectionLimiter *request.RateLimiter
+}
+
+type RouterConfig struct {
+	// IntrospectionKey is the bearer token other services present to
+	// introspect API keys; introspection is refused when it is empty.
+	IntrospectionKey   string
+	IntrospectionRPS   float64
+	IntrospectionBurst int
 }
 
-func NewRouter(userService service.UserService) *router {
-	return &router{user: *NewHandler(userService)}
+func NewRouter(userService usecase.UserService, tokenManager token.TokenManager, cfg RouterConfig) *router {
+	return &router{
+		user:                 *NewHandler(userService, tokenManager),
+		auth:                 token.NewMiddleware(tokenManager).WithSessionCheck(userService),
+		config:               cfg,
+		introspectionLimiter: request.NewRateLimiter(cfg.IntrospectionRPS, cfg.IntrospectionBurst),
+	}
 }
 
 func (r *router) GetRoutes() http.Handler {
 
 	router := httprouter.New()
 
+	router.HandlerFunc(http.MethodGet, "/.well-known/jwks.json", r.user.JWKS)
+
 	router.HandlerFunc(http.MethodPost, "/v1/user/signup", r.user.RegisterUser)
 	router.HandlerFunc(http.MethodPost, "/v1/user/signin", r.user.LoginUser)
+	router.HandlerFunc(http.MethodPost, "/v1/user/signin/mfa", r.user.CompleteMFASignIn)
+	router.HandlerFunc(http.MethodPost, "/v1/user/signin/magic", r.user.RequestMagicLink)
+	router.HandlerFunc(http.MethodPost, "/v1/user/signin/magic/verify", r.user.SignInWithMagicLink)
+	router.HandlerFunc(http.MethodGet, "/v1/user/oidc/:provider/login", r.user.BeginOIDCLogin)
+	router.HandlerFunc(http.MethodGet, "/v1/user/oidc/:provider/callback", r.user.CompleteOIDCLogin)
+	router.HandlerFunc(http.MethodPost, "/v1/user/verify", r.user.VerifyEmail)
+	router.HandlerFunc(http.MethodPost, "/v1/user/verify/resend", r.user.ResendVerification)
+	router.HandlerFunc(http.MethodPost, "/v1/user/password/forgot", r.user.ForgotPassword)
+	router.HandlerFunc(http.MethodPost, "/v1/user/password/reset", r.user.ResetPassword)
+	router.HandlerFunc(http.MethodPost, "/v1/user/unlock", r.user.UnlockAccount)
+	router.HandlerFunc(http.MethodPost, "/v1/user/refresh", r.user.RefreshToken)
+	router.HandlerFunc(http.MethodPost, "/v1/user/logout", r.user.Logout)
+	router.HandlerFunc(http.MethodPost, "/v1/user/logout/all", r.auth.RequireAuth(r.user.LogoutAll))
+
+	router.HandlerFunc(http.MethodGet, "/v1/user/me", r.auth.RequireAuth(r.user.ShowProfile))
+	router.HandlerFunc(http.MethodPatch, "/v1/user/me", r.auth.RequireAuth(r.user.UpdateProfile))
+	router.HandlerFunc(http.MethodDelete, "/v1/user/me", r.auth.RequireAuth(r.user.DeleteAccount))
+	router.HandlerFunc(http.MethodPost, "/v1/user/me/email", r.auth.RequireAuth(r.user.ChangeEmail))
+	router.HandlerFunc(http.MethodPost, "/v1/user/me/password", r.auth.RequireAuth(r.user.ChangePassword))
+
+	router.HandlerFunc(http.MethodPost, "/v1/user/mfa/totp", r.auth.RequireAuth(r.user.EnrollTOTP))
+	router.HandlerFunc(http.MethodPost, "/v1/user/mfa/totp/confirm", r.auth.RequireAuth(r.user.ConfirmTOTP))
+	router.HandlerFunc(http.MethodDelete, "/v1/user/mfa/totp", r.auth.RequireAuth(r.user.DisableTOTP))
+
+	router.HandlerFunc(http.MethodGet, "/v1/user/sessions", r.auth.RequireAuth(r.user.ListSessions))
+	router.HandlerFunc(http.MethodGet, "/v1/user/sessions/current", r.auth.RequireAuth(r.user.CheckSession))
+	router.HandlerFunc(http.MethodDelete, "/v1/user/sessions/:id", r.auth.RequireAuth(r.user.RevokeSession))
+
+	router.HandlerFunc(http.MethodGet, "/v1/orgs", r.auth.RequireAuth(r.user.ListOrganizations))
+	router.HandlerFunc(http.MethodPost, "/v1/orgs", r.auth.RequireAuth(r.user.CreateOrganization))
+	router.HandlerFunc(http.MethodGet, "/v1/orgs/:id", r.auth.RequireAuth(r.user.ShowOrganization))
+	router.HandlerFunc(http.MethodPost, "/v1/orgs/:id/switch", r.auth.RequireAuth(r.user.SwitchOrganization))
+	router.HandlerFunc(http.MethodGet, "/v1/orgs/:id/members", r.auth.RequireAuth(r.user.ListMembers))
+	// Members are only added by invitation, so that nobody joins an
+	// organization without accepting and unknown addresses are not revealed.
+	router.HandlerFunc(http.MethodPost, "/v1/orgs/:id/members", r.auth.RequireAuth(r.user.InviteMember))
+	router.HandlerFunc(http.MethodPatch, "/v1/orgs/:id/members/:user", r.auth.RequireAuth(r.user.UpdateMember))
+	router.HandlerFunc(http.MethodDelete, "/v1/orgs/:id/members/:user", r.auth.RequireAuth(r.user.RemoveMember))
+	router.HandlerFunc(http.MethodGet, "/v1/orgs/:id/invitations", r.auth.RequireAuth(r.user.ListInvitations))
+	router.HandlerFunc(http.MethodPost, "/v1/orgs/:id/invitations", r.auth.RequireAuth(r.user.InviteMember))
+	router.HandlerFunc(http.MethodDelete, "/v1/orgs/:id/invitations/:invitation", r.auth.RequireAuth(r.user.RevokeInvitation))
+	router.HandlerFunc(http.MethodPost, "/v1/invitations/accept", r.user.AcceptInvitation)
+
+	router.HandlerFunc(http.MethodPost, "/v1/user/privacy/export", r.auth.RequireAuth(r.user.RequestDataExport))
+	router.HandlerFunc(http.MethodPost, "/v1/user/privacy/erasure", r.auth.RequireAuth(r.user.RequestErasure))
+	router.HandlerFunc(http.MethodGet, "/v1/user/privacy/requests/:id", r.auth.RequireAuth(r.user.ShowOwnPrivacyRequest))
+	router.HandlerFunc(http.MethodGet, "/v1/user/privacy/requests/:id/archive", r.auth.RequireAuth(r.user.DownloadOwnPrivacyArchive))
+
+	router.HandlerFunc(http.MethodPost, "/v1/user/api-keys", r.auth.RequireAuth(r.user.CreateAPIKey))
+	router.HandlerFunc(http.MethodGet, "/v1/user/api-keys", r.auth.RequireAuth(r.user.ListAPIKeys))
+	router.HandlerFunc(http.MethodDelete, "/v1/user/api-keys/:id", r.auth.RequireAuth(r.user.RevokeAPIKey))
+	router.HandlerFunc(http.MethodPost, "/v1/api-keys/introspect", r.introspectionLimiter.Limit(token.RequireServiceKey(r.config.IntrospectionKey, r.user.IntrospectAPIKey)))
+
+	router.HandlerFunc(http.MethodGet, "/v1/users", r.auth.RequirePermission("users:admin", r.user.ListUsers))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id", r.auth.RequirePermission("users:admin", r.user.ShowUser))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/disable", r.auth.RequirePermission("users:admin", r.user.DisableUser))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/enable", r.auth.RequirePermission("users:admin", r.user.EnableUser))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/password/reset", r.auth.RequirePermission("users:admin", r.user.ForcePasswordReset))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/audit", r.auth.RequirePermission("users:admin", r.user.ShowAuditLog))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/privacy/export", r.auth.RequirePermission("users:admin", r.user.AdminRequestDataExport))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/privacy/erasure", r.auth.RequirePermission("users:admin", r.user.AdminRequestErasure))
+	router.HandlerFunc(http.MethodGet, "/v1/privacy/requests/:id", r.auth.RequirePermission("users:admin", r.user.ShowPrivacyRequest))
+	router.HandlerFunc(http.MethodGet, "/v1/privacy/requests/:id/archive", r.auth.RequirePermission("users:admin", r.user.DownloadPrivacyArchive))
+
+	router.HandlerFunc(http.MethodGet, "/v1/roles", r.auth.RequirePermission("roles:write", r.user.ListRoles))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/roles", r.auth.RequirePermission("roles:write", r.user.ShowUserRoles))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/roles", r.auth.RequirePermission("roles:write", r.user.AssignRole))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/:id/roles/:role", r.auth.RequirePermission("roles:write", r.user.RevokeRole))
 
-	return router
+	return r.auth.Authenticate(router)
 }
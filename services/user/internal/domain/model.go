@@ -6,8 +6,174 @@ type User struct {
 	ID           int64     `json:"ID,omitempty"`
 	Name         string    `json:"name,omitempty"`
 	Email        string    `json:"email,omitempty"`
-	HashPassword string    `json:"hashPassword,omitempty"`
+	HashPassword string    `json:"-"`
 	CreatedAt    time.Time `json:"createdAt,omitempty"`
+
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	Version         int        `json:"version"`
+
+	DisabledAt            *time.Time `json:"disabledAt,omitempty"`
+	PasswordResetRequired bool       `json:"passwordResetRequired,omitempty"`
+}
+
+type RefreshToken struct {
+	Hash      []byte
+	UserID    int64
+	FamilyID  string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// Session is one sign-in on one device. It lives as long as its refresh
+// token family and is named by the sid claim of access tokens.
+type Session struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	FamilyID   string     `json:"-"`
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	RevokedAt  *time.Time `json:"-"`
+	OrgID      *int64     `json:"orgId,omitempty"`
+	Current    bool       `json:"current"`
+}
+
+// UserToken is a hashed single-use token sent to the user out of band, e.g.
+// in an email verification link. Scope says what it may be exchanged for.
+type UserToken struct {
+	Hash      []byte
+	UserID    int64
+	Scope     string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// LoginFailure counts failed sign-ins for a key such as an email address or
+// a client IP.
+type LoginFailure struct {
+	Key          string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+type TOTP struct {
+	UserID int64
+	// Secret is only stored in plaintext by rows written before secrets were
+	// encrypted; SecretCiphertext holds it otherwise.
+	Secret           string
+	SecretCiphertext []byte
+	ConfirmedAt      *time.Time
+	LastUsedStep     int64
+	CreatedAt        time.Time
+}
+
+type Role struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// APIKey lets a program act on behalf of its owner, limited to Scopes.
+// Only the prefix is shown again after creation.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       []byte     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	OrgID      *int64     `json:"orgId,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// ExternalIdentity links an account at an OpenID Connect provider, named
+// by its subject, to a local user.
+type ExternalIdentity struct {
+	Provider  string
+	Subject   string
+	UserID    int64
+	Email     string
+	CreatedAt time.Time
+}
+
+// OIDCLoginState is kept between redirecting the user to a provider and the
+// provider redirecting back, keyed by the hash of the state parameter.
+type OIDCLoginState struct {
+	Hash         []byte
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// AuditEvent records an administrative action taken on an account.
+type AuditEvent struct {
+	ID           int64          `json:"id"`
+	ActorID      int64          `json:"actorId"`
+	TargetUserID int64          `json:"targetUserId"`
+	Action       string         `json:"action"`
+	Details      map[string]any `json:"details,omitempty"`
+	CreatedAt    time.Time      `json:"createdAt"`
+}
+
+// Organization is a tenant. Contracts and orders belong to one, and users
+// act in one at a time.
+type Organization struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy *int64    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	Version   int       `json:"version"`
+	// Role is the role of the user the organization was loaded for.
+	Role string `json:"role,omitempty"`
+}
+
+// OrganizationMember is a user's membership of an organization, with their
+// role there: owner, admin or member.
+type OrganizationMember struct {
+	OrgID     int64     `json:"-"`
+	UserID    int64     `json:"userId"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Invitation asks someone, by email address, to join an organization with
+// the given role. Only the hash of its token is stored.
+type Invitation struct {
+	ID         int64      `json:"id"`
+	OrgID      int64      `json:"orgId"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	TokenHash  []byte     `json:"-"`
+	InvitedBy  *int64     `json:"invitedBy,omitempty"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	AcceptedAt *time.Time `json:"acceptedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// PrivacyRequest is a data-subject request to export or erase everything
+// held about a user, across services. It is processed in the background;
+// Steps reports the state of each part.
+type PrivacyRequest struct {
+	ID          int64             `json:"id"`
+	UserID      int64             `json:"userId"`
+	RequestedBy int64             `json:"requestedBy"`
+	Kind        string            `json:"kind"`
+	Status      string            `json:"status"`
+	Steps       map[string]string `json:"steps"`
+	Error       string            `json:"error,omitempty"`
+	Attempts    int               `json:"-"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	CompletedAt *time.Time        `json:"completedAt,omitempty"`
 }
 
 //type password struct {
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS roles (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id bigserial PRIMARY KEY,
+    code text NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS roles_permissions (
+    role_id bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+    permission_id bigint NOT NULL REFERENCES permissions ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS users_roles (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    role_id bigint NOT NULL REFERENCES roles ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO roles (name)
+VALUES ('user'), ('editor'), ('admin');
+
+INSERT INTO permissions (code)
+VALUES
+    ('contracts:read'),
+    ('contracts:write'),
+    ('submissions:read'),
+    ('submissions:write'),
+    ('submissions:read:any'),
+    ('roles:write');
+
+INSERT INTO roles_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id
+FROM roles, permissions
+WHERE (roles.name = 'user' AND permissions.code IN ('contracts:read', 'submissions:read', 'submissions:write'))
+   OR (roles.name = 'editor' AND permissions.code IN ('contracts:read', 'contracts:write'))
+   OR roles.name = 'admin';
@@ -0,0 +1,6 @@
+DELETE FROM permissions WHERE code = 'users:admin';
+
+DROP TABLE IF EXISTS audit_log;
+
+ALTER TABLE users DROP COLUMN IF EXISTS password_reset_required;
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS sessions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    family_id text NOT NULL UNIQUE,
+    user_agent text NOT NULL DEFAULT '',
+    ip text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    revoked_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+
+-- Every refresh token family issued so far becomes a session, so existing
+-- sign-ins keep working.
+INSERT INTO sessions (user_id, family_id, created_at, last_seen_at, revoked_at)
+SELECT user_id, family_id, MIN(created_at), MAX(created_at),
+       CASE WHEN bool_and(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY user_id, family_id
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS privacy_requests (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    requested_by bigint NOT NULL,
+    kind text NOT NULL CHECK (kind IN ('export', 'erasure')),
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    steps jsonb NOT NULL DEFAULT '{}',
+    archive jsonb,
+    error text NOT NULL DEFAULT '',
+    attempts integer NOT NULL DEFAULT 0,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    completed_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS privacy_requests_status_idx ON privacy_requests (status, id);
+
+-- At most one open request of each kind per user.
+CREATE UNIQUE INDEX IF NOT EXISTS privacy_requests_open_unique ON privacy_requests (user_id, kind)
+    WHERE status IN ('pending', 'running');
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS organizations (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    created_by bigint,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    org_id bigint NOT NULL REFERENCES organizations ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    role text NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS organization_members_user_id_idx ON organization_members (user_id);
+
+-- The organization a session acts in, and the one an API key is bound to.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS org_id bigint REFERENCES organizations ON DELETE SET NULL;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS org_id bigint REFERENCES organizations ON DELETE CASCADE;
+
+-- Every existing user gets a personal organization they own.
+WITH created AS (
+    INSERT INTO organizations (name, created_by)
+    SELECT name || '''s organization', id FROM users
+    RETURNING id, created_by
+)
+INSERT INTO organization_members (org_id, user_id, role)
+SELECT id, created_by, 'owner' FROM created;
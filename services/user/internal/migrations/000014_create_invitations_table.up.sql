@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS invitations (
+    id bigserial PRIMARY KEY,
+    org_id bigint NOT NULL REFERENCES organizations ON DELETE CASCADE,
+    email text NOT NULL,
+    role text NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    token_hash bytea NOT NULL,
+    invited_by bigint REFERENCES users ON DELETE SET NULL,
+    expires_at timestamp(0) with time zone NOT NULL,
+    accepted_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS invitations_token_hash_idx ON invitations (token_hash);
+
+-- An address has at most one open invitation per organization; inviting it
+-- again replaces the old one.
+CREATE UNIQUE INDEX IF NOT EXISTS invitations_pending_unique ON invitations (org_id, lower(email)) WHERE accepted_at IS NULL;
@@ -0,0 +1,9 @@
+-- Encrypted secrets cannot be decrypted here; those authenticators have to
+-- be enrolled again.
+DELETE FROM user_totp WHERE secret IS NULL;
+
+ALTER TABLE user_totp DROP CONSTRAINT IF EXISTS user_totp_secret_check;
+
+ALTER TABLE user_totp ALTER COLUMN secret SET NOT NULL;
+
+ALTER TABLE user_totp DROP COLUMN IF EXISTS secret_ciphertext;
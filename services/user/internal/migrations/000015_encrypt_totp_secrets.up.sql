@@ -0,0 +1,8 @@
+-- TOTP secrets are kept encrypted in secret_ciphertext. The user service
+-- encrypts the remaining plaintext secrets at startup and clears them.
+ALTER TABLE user_totp ADD COLUMN IF NOT EXISTS secret_ciphertext bytea;
+
+ALTER TABLE user_totp ALTER COLUMN secret DROP NOT NULL;
+
+ALTER TABLE user_totp ADD CONSTRAINT user_totp_secret_check
+    CHECK (secret IS NOT NULL OR secret_ciphertext IS NOT NULL);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS oidc_login_states_expires_at_idx;
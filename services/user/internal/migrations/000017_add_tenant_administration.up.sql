@@ -0,0 +1,11 @@
+-- Lets administrators assign the contracts and orders created before
+-- organizations existed to an organization.
+INSERT INTO permissions (code)
+VALUES ('tenants:admin')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO roles_permissions (role_id, permission_id)
+SELECT roles.id, permissions.id
+FROM roles, permissions
+WHERE roles.name = 'admin' AND permissions.code = 'tenants:admin'
+ON CONFLICT DO NOTHING;
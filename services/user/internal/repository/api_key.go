@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"microservices/services/user/internal/domain"
+)
+
+type apiKeyRepo struct {
+	db DB
+}
+
+type APIKey interface {
+	Insert(ctx context.Context, key *domain.APIKey) error
+	GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	GetAllForUser(ctx context.Context, userID int64) ([]*domain.APIKey, error)
+	Delete(ctx context.Context, id, userID int64) error
+	TouchLastUsed(ctx context.Context, id int64) error
+}
+
+func NewAPIKeyRepo(db DB) *apiKeyRepo {
+	return &apiKeyRepo{db: db}
+}
+
+func (s *apiKeyRepo) Insert(ctx context.Context, key *domain.APIKey) error {
+	query := `
+	INSERT INTO api_keys (user_id, name, prefix, hash, scopes, expires_at, org_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, created_at`
+
+	args := []any{key.UserID, key.Name, key.Prefix, key.Hash, key.Scopes, key.ExpiresAt, key.OrgID}
+
+	return s.db.QueryRow(ctx, query, args...).Scan(&key.ID, &key.CreatedAt)
+}
+
+func (s *apiKeyRepo) GetByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	query := `
+	SELECT id, user_id, name, prefix, hash, scopes, expires_at, last_used_at, created_at, org_id
+	FROM api_keys
+	WHERE prefix = $1`
+
+	var key domain.APIKey
+	err := s.db.QueryRow(ctx, query, prefix).Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.Hash,
+		&key.Scopes,
+		&key.ExpiresAt,
+		&key.LastUsedAt,
+		&key.CreatedAt,
+		&key.OrgID,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &key, nil
+}
+
+func (s *apiKeyRepo) GetAllForUser(ctx context.Context, userID int64) ([]*domain.APIKey, error) {
+	query := `
+	SELECT id, user_id, name, prefix, hash, scopes, expires_at, last_used_at, created_at, org_id
+	FROM api_keys
+	WHERE user_id = $1
+	ORDER BY id`
+
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*domain.APIKey{}
+
+	for rows.Next() {
+		var key domain.APIKey
+
+		err := rows.Scan(
+			&key.ID,
+			&key.UserID,
+			&key.Name,
+			&key.Prefix,
+			&key.Hash,
+			&key.Scopes,
+			&key.ExpiresAt,
+			&key.LastUsedAt,
+			&key.CreatedAt,
+			&key.OrgID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *apiKeyRepo) Delete(ctx context.Context, id, userID int64) error {
+	query := `
+	DELETE FROM api_keys
+	WHERE id = $1 AND user_id = $2`
+
+	result, err := s.db.Exec(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// TouchLastUsed records a use of the key. Writes are limited to one per
+// minute so that busy keys do not turn every request into an UPDATE.
+func (s *apiKeyRepo) TouchLastUsed(ctx context.Context, id int64) error {
+	query := `
+	UPDATE api_keys
+	SET last_used_at = NOW()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+
+	_, err := s.db.Exec(ctx, query, id)
+	return err
+}
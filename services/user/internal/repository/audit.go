@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"microservices/services/user/internal/domain"
+)
+
+type auditRepo struct {
+	db DB
+}
+
+type Audit interface {
+	Insert(ctx context.Context, event *domain.AuditEvent) error
+	GetAllForUser(ctx context.Context, userID int64, filters Filters) ([]*domain.AuditEvent, error)
+}
+
+func NewAuditRepo(db DB) *auditRepo {
+	return &auditRepo{db: db}
+}
+
+func (s *auditRepo) Insert(ctx context.Context, event *domain.AuditEvent) error {
+	query := `
+	INSERT INTO audit_log (actor_id, target_user_id, action, details)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at`
+
+	details := event.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+
+	args := []any{event.ActorID, event.TargetUserID, event.Action, details}
+
+	return s.db.QueryRow(ctx, query, args...).Scan(&event.ID, &event.CreatedAt)
+}
+
+// GetAllForUser returns the audit trail of one account, newest first.
+func (s *auditRepo) GetAllForUser(ctx context.Context, userID int64, filters Filters) ([]*domain.AuditEvent, error) {
+	query := `
+	SELECT id, actor_id, target_user_id, action, details, created_at
+	FROM audit_log
+	WHERE target_user_id = $1
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.Query(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*domain.AuditEvent{}
+
+	for rows.Next() {
+		var event domain.AuditEvent
+
+		err := rows.Scan(
+			&event.ID,
+			&event.ActorID,
+			&event.TargetUserID,
+			&event.Action,
+			&event.Details,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
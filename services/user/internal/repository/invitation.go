@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"microservices/services/user/internal/domain"
+)
+
+type invitationRepo struct {
+	db DB
+}
+
+type Invitation interface {
+	Insert(ctx context.Context, invitation *domain.Invitation) error
+	GetPending(ctx context.Context, hash []byte) (*domain.Invitation, error)
+	GetPendingByID(ctx context.Context, orgID, id int64) (*domain.Invitation, error)
+	GetAllPending(ctx context.Context, orgID int64) ([]*domain.Invitation, error)
+	MarkAccepted(ctx context.Context, id int64) error
+	Delete(ctx context.Context, orgID, id int64) error
+}
+
+func NewInvitationRepo(db DB) *invitationRepo {
+	return &invitationRepo{db: db}
+}
+
+// Insert stores the invitation, replacing any open invitation of the same
+// address to the same organization.
+func (s *invitationRepo) Insert(ctx context.Context, invitation *domain.Invitation) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+	DELETE FROM invitations
+	WHERE org_id = $1 AND lower(email) = lower($2) AND accepted_at IS NULL`, invitation.OrgID, invitation.Email)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO invitations (org_id, email, role, token_hash, invited_by, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at`
+
+	args := []any{invitation.OrgID, invitation.Email, invitation.Role, invitation.TokenHash, invitation.InvitedBy, invitation.ExpiresAt}
+
+	err = tx.QueryRow(ctx, query, args...).Scan(&invitation.ID, &invitation.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+const invitationQuery = `
+	SELECT id, org_id, email, role, token_hash, invited_by, expires_at, accepted_at, created_at
+	FROM invitations`
+
+// GetPending returns the unexpired, unaccepted invitation with the token
+// hash.
+func (s *invitationRepo) GetPending(ctx context.Context, hash []byte) (*domain.Invitation, error) {
+	query := invitationQuery + `
+	WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > NOW()`
+
+	return s.get(ctx, query, hash)
+}
+
+func (s *invitationRepo) GetPendingByID(ctx context.Context, orgID, id int64) (*domain.Invitation, error) {
+	query := invitationQuery + `
+	WHERE id = $1 AND org_id = $2 AND accepted_at IS NULL`
+
+	return s.get(ctx, query, id, orgID)
+}
+
+func (s *invitationRepo) get(ctx context.Context, query string, args ...any) (*domain.Invitation, error) {
+	var invitation domain.Invitation
+	err := s.db.QueryRow(ctx, query, args...).Scan(
+		&invitation.ID,
+		&invitation.OrgID,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.TokenHash,
+		&invitation.InvitedBy,
+		&invitation.ExpiresAt,
+		&invitation.AcceptedAt,
+		&invitation.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &invitation, nil
+}
+
+// GetAllPending returns the organization's open invitations, expired ones
+// included, newest first.
+func (s *invitationRepo) GetAllPending(ctx context.Context, orgID int64) ([]*domain.Invitation, error) {
+	query := invitationQuery + `
+	WHERE org_id = $1 AND accepted_at IS NULL
+	ORDER BY created_at DESC, id DESC`
+
+	rows, err := s.db.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []*domain.Invitation{}
+
+	for rows.Next() {
+		var invitation domain.Invitation
+
+		err := rows.Scan(
+			&invitation.ID,
+			&invitation.OrgID,
+			&invitation.Email,
+			&invitation.Role,
+			&invitation.TokenHash,
+			&invitation.InvitedBy,
+			&invitation.ExpiresAt,
+			&invitation.AcceptedAt,
+			&invitation.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, &invitation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// MarkAccepted uses the invitation up. It fails with ErrRecordNotFound if
+// the invitation was accepted, revoked or has expired in the meantime,
+// which keeps tokens single-use.
+func (s *invitationRepo) MarkAccepted(ctx context.Context, id int64) error {
+	query := `
+	UPDATE invitations
+	SET accepted_at = NOW()
+	WHERE id = $1 AND accepted_at IS NULL AND expires_at > NOW()`
+
+	result, err := s.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *invitationRepo) Delete(ctx context.Context, orgID, id int64) error {
+	query := `
+	DELETE FROM invitations
+	WHERE id = $1 AND org_id = $2 AND accepted_at IS NULL`
+
+	result, err := s.db.Exec(ctx, query, id, orgID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
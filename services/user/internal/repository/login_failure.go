@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"microservices/services/user/internal/domain"
+	"time"
+)
+
+type loginFailureRepo struct {
+	db DB
+}
+
+type LoginFailure interface {
+	Get(ctx context.Context, key string) (*domain.LoginFailure, error)
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+func NewLoginFailureRepo(db DB) *loginFailureRepo {
+	return &loginFailureRepo{db: db}
+}
+
+func (s *loginFailureRepo) Get(ctx context.Context, key string) (*domain.LoginFailure, error) {
+	query := `
+	SELECT key, failures, last_failed_at, locked_until
+	FROM login_failures
+	WHERE key = $1`
+
+	var failure domain.LoginFailure
+	err := s.db.QueryRow(ctx, query, key).Scan(
+		&failure.Key,
+		&failure.Failures,
+		&failure.LastFailedAt,
+		&failure.LockedUntil,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &failure, nil
+}
+
+// RecordFailure increments the failure counter for key and returns the new
+// count. The count starts over when the previous failure is older than window.
+func (s *loginFailureRepo) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	query := `
+	INSERT INTO login_failures (key, failures, last_failed_at)
+	VALUES ($1, 1, NOW())
+	ON CONFLICT (key) DO UPDATE
+	SET failures = CASE
+			WHEN login_failures.last_failed_at < NOW() - make_interval(secs => $2) THEN 1
+			ELSE login_failures.failures + 1
+		END,
+		last_failed_at = NOW()
+	RETURNING failures`
+
+	var failures int
+	err := s.db.QueryRow(ctx, query, key, window.Seconds()).Scan(&failures)
+	return failures, err
+}
+
+func (s *loginFailureRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	query := `
+	UPDATE login_failures
+	SET locked_until = $2
+	WHERE key = $1`
+
+	_, err := s.db.Exec(ctx, query, key, until)
+	return err
+}
+
+func (s *loginFailureRepo) Reset(ctx context.Context, key string) error {
+	query := `
+	DELETE FROM login_failures
+	WHERE key = $1`
+
+	_, err := s.db.Exec(ctx, query, key)
+	return err
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"microservices/services/user/internal/domain"
+)
+
+type mfaRepo struct {
+	db DB
+}
+
+type MFA interface {
+	GetTOTP(ctx context.Context, userID int64) (*domain.TOTP, error)
+	UpsertTOTP(ctx context.Context, userID int64, ciphertext []byte) error
+	GetPlaintextTOTPs(ctx context.Context) ([]*domain.TOTP, error)
+	EncryptTOTP(ctx context.Context, userID int64, ciphertext []byte) error
+	ConfirmTOTP(ctx context.Context, userID int64) error
+	UseTOTPStep(ctx context.Context, userID int64, step int64) error
+	DeleteTOTP(ctx context.Context, userID int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes [][]byte) error
+	ConsumeRecoveryCode(ctx context.Context, userID int64, hash []byte) error
+}
+
+func NewMFARepo(db DB) *mfaRepo {
+	return &mfaRepo{db: db}
+}
+
+func (s *mfaRepo) GetTOTP(ctx context.Context, userID int64) (*domain.TOTP, error) {
+	query := `
+	SELECT user_id, COALESCE(secret, ''), secret_ciphertext, confirmed_at, last_used_step, created_at
+	FROM user_totp
+	WHERE user_id = $1`
+
+	var totp domain.TOTP
+	err := s.db.QueryRow(ctx, query, userID).Scan(
+		&totp.UserID,
+		&totp.Secret,
+		&totp.SecretCiphertext,
+		&totp.ConfirmedAt,
+		&totp.LastUsedStep,
+		&totp.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &totp, nil
+}
+
+// UpsertTOTP stores a new unconfirmed, encrypted secret. A confirmed secret
+// is never replaced; ErrDuplicate is returned instead.
+func (s *mfaRepo) UpsertTOTP(ctx context.Context, userID int64, ciphertext []byte) error {
+	query := `
+	INSERT INTO user_totp (user_id, secret_ciphertext)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE
+	SET secret = NULL, secret_ciphertext = EXCLUDED.secret_ciphertext, last_used_step = 0, created_at = NOW()
+	WHERE user_totp.confirmed_at IS NULL`
+
+	result, err := s.db.Exec(ctx, query, userID, ciphertext)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrDuplicate
+	}
+	return nil
+}
+
+// GetPlaintextTOTPs returns the secrets still stored unencrypted.
+func (s *mfaRepo) GetPlaintextTOTPs(ctx context.Context) ([]*domain.TOTP, error) {
+	query := `
+	SELECT user_id, secret, confirmed_at, last_used_step, created_at
+	FROM user_totp
+	WHERE secret IS NOT NULL`
+
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []*domain.TOTP
+	for rows.Next() {
+		var totp domain.TOTP
+		err := rows.Scan(
+			&totp.UserID,
+			&totp.Secret,
+			&totp.ConfirmedAt,
+			&totp.LastUsedStep,
+			&totp.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, &totp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// EncryptTOTP replaces a plaintext secret with its ciphertext. A secret that
+// was replaced or encrypted in the meantime is left alone.
+func (s *mfaRepo) EncryptTOTP(ctx context.Context, userID int64, ciphertext []byte) error {
+	query := `
+	UPDATE user_totp
+	SET secret = NULL, secret_ciphertext = $2
+	WHERE user_id = $1 AND secret IS NOT NULL`
+
+	_, err := s.db.Exec(ctx, query, userID, ciphertext)
+	return err
+}
+
+func (s *mfaRepo) ConfirmTOTP(ctx context.Context, userID int64) error {
+	query := `
+	UPDATE user_totp
+	SET confirmed_at = NOW()
+	WHERE user_id = $1 AND confirmed_at IS NULL`
+
+	result, err := s.db.Exec(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+// UseTOTPStep records the time step of an accepted code. It returns
+// ErrEditConflict if that step or a later one was already used, which stops
+// a code from being replayed inside its validity window.
+func (s *mfaRepo) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	query := `
+	UPDATE user_totp
+	SET last_used_step = $2
+	WHERE user_id = $1 AND last_used_step < $2`
+
+	result, err := s.db.Exec(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+func (s *mfaRepo) DeleteTOTP(ctx context.Context, userID int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *mfaRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes [][]byte) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		_, err = tx.Exec(ctx, `INSERT INTO mfa_recovery_codes (hash, user_id) VALUES ($1, $2)`, hash, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *mfaRepo) ConsumeRecoveryCode(ctx context.Context, userID int64, hash []byte) error {
+	query := `
+	DELETE FROM mfa_recovery_codes
+	WHERE user_id = $1 AND hash = $2`
+
+	result, err := s.db.Exec(ctx, query, userID, hash)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
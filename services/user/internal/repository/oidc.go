@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"microservices/services/user/internal/domain"
+	"strings"
+)
+
+type oidcRepo struct {
+	db DB
+}
+
+type OIDC interface {
+	InsertLoginState(ctx context.Context, state *domain.OIDCLoginState) error
+	ConsumeLoginState(ctx context.Context, provider string, hash []byte) (*domain.OIDCLoginState, error)
+	DeleteExpiredLoginStates(ctx context.Context) error
+	GetIdentity(ctx context.Context, provider, subject string) (*domain.ExternalIdentity, error)
+	GetIdentitiesForUser(ctx context.Context, userID int64) ([]*domain.ExternalIdentity, error)
+	InsertIdentity(ctx context.Context, identity *domain.ExternalIdentity) error
+}
+
+func NewOIDCRepo(db DB) *oidcRepo {
+	return &oidcRepo{db: db}
+}
+
+func (s *oidcRepo) InsertLoginState(ctx context.Context, state *domain.OIDCLoginState) error {
+	query := `
+	INSERT INTO oidc_login_states (hash, provider, nonce, code_verifier, expires_at)
+	VALUES ($1, $2, $3, $4, $5)`
+
+	args := []any{state.Hash, state.Provider, state.Nonce, state.CodeVerifier, state.ExpiresAt}
+
+	_, err := s.db.Exec(ctx, query, args...)
+	return err
+}
+
+// ConsumeLoginState deletes and returns an unexpired login state, so that
+// every state can complete at most one sign-in.
+func (s *oidcRepo) ConsumeLoginState(ctx context.Context, provider string, hash []byte) (*domain.OIDCLoginState, error) {
+	query := `
+	DELETE FROM oidc_login_states
+	WHERE hash = $1 AND provider = $2 AND expires_at > NOW()
+	RETURNING hash, provider, nonce, code_verifier, expires_at`
+
+	var state domain.OIDCLoginState
+	err := s.db.QueryRow(ctx, query, hash, provider).Scan(
+		&state.Hash,
+		&state.Provider,
+		&state.Nonce,
+		&state.CodeVerifier,
+		&state.ExpiresAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &state, nil
+}
+
+func (s *oidcRepo) DeleteExpiredLoginStates(ctx context.Context) error {
+	query := `
+	DELETE FROM oidc_login_states
+	WHERE expires_at <= NOW()`
+
+	_, err := s.db.Exec(ctx, query)
+	return err
+}
+
+func (s *oidcRepo) GetIdentity(ctx context.Context, provider, subject string) (*domain.ExternalIdentity, error) {
+	query := `
+	SELECT provider, subject, user_id, email, created_at
+	FROM user_identities
+	WHERE provider = $1 AND subject = $2`
+
+	var identity domain.ExternalIdentity
+	err := s.db.QueryRow(ctx, query, provider, subject).Scan(
+		&identity.Provider,
+		&identity.Subject,
+		&identity.UserID,
+		&identity.Email,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &identity, nil
+}
+
+func (s *oidcRepo) GetIdentitiesForUser(ctx context.Context, userID int64) ([]*domain.ExternalIdentity, error) {
+	query := `
+	SELECT provider, subject, user_id, email, created_at
+	FROM user_identities
+	WHERE user_id = $1
+	ORDER BY created_at`
+
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []*domain.ExternalIdentity{}
+
+	for rows.Next() {
+		var identity domain.ExternalIdentity
+
+		err := rows.Scan(
+			&identity.Provider,
+			&identity.Subject,
+			&identity.UserID,
+			&identity.Email,
+			&identity.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, &identity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (s *oidcRepo) InsertIdentity(ctx context.Context, identity *domain.ExternalIdentity) error {
+	query := `
+	INSERT INTO user_identities (provider, subject, user_id, email)
+	VALUES ($1, $2, $3, $4)
+	RETURNING created_at`
+
+	args := []any{identity.Provider, identity.Subject, identity.UserID, identity.Email}
+
+	err := s.db.QueryRow(ctx, query, args...).Scan(&identity.CreatedAt)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "user_identities_pkey"):
+			return ErrDuplicate
+		default:
+			return err
+		}
+	}
+	return nil
+}
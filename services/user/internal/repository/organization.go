@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"microservices/services/user/internal/domain"
+	"strings"
+)
+
+type organizationRepo struct {
+	db DB
+}
+
+type Organization interface {
+	Insert(ctx context.Context, org *domain.Organization, ownerID int64) error
+	GetByID(ctx context.Context, id int64) (*domain.Organization, error)
+	GetAllForUser(ctx context.Context, userID int64) ([]*domain.Organization, error)
+	GetMember(ctx context.Context, orgID, userID int64) (*domain.OrganizationMember, error)
+	GetMembers(ctx context.Context, orgID int64) ([]*domain.OrganizationMember, error)
+	GetDefaultMembership(ctx context.Context, userID int64) (*domain.OrganizationMember, error)
+	AddMember(ctx context.Context, orgID, userID int64, role string) error
+	UpdateMemberRole(ctx context.Context, orgID, userID int64, role string) error
+	RemoveMember(ctx context.Context, orgID, userID int64) error
+	CountOwners(ctx context.Context, orgID int64) (int, error)
+}
+
+func NewOrganizationRepo(db DB) *organizationRepo {
+	return &organizationRepo{db: db}
+}
+
+// Insert creates the organization with ownerID as its first owner.
+func (s *organizationRepo) Insert(ctx context.Context, org *domain.Organization, ownerID int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+	INSERT INTO organizations (name, created_by)
+	VALUES ($1, $2)
+	RETURNING id, created_at, version`
+
+	err = tx.QueryRow(ctx, query, org.Name, ownerID).Scan(&org.ID, &org.CreatedAt, &org.Version)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, 'owner')`, org.ID, ownerID)
+	if err != nil {
+		return err
+	}
+
+	org.CreatedBy = &ownerID
+	org.Role = "owner"
+	return tx.Commit(ctx)
+}
+
+func (s *organizationRepo) GetByID(ctx context.Context, id int64) (*domain.Organization, error) {
+	query := `
+	SELECT id, name, created_by, created_at, version
+	FROM organizations
+	WHERE id = $1`
+
+	var org domain.Organization
+	err := s.db.QueryRow(ctx, query, id).Scan(
+		&org.ID,
+		&org.Name,
+		&org.CreatedBy,
+		&org.CreatedAt,
+		&org.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &org, nil
+}
+
+// GetAllForUser returns the organizations the user belongs to, with their
+// role in each, oldest membership first.
+func (s *organizationRepo) GetAllForUser(ctx context.Context, userID int64) ([]*domain.Organization, error) {
+	query := `
+	SELECT o.id, o.name, o.created_by, o.created_at, o.version, m.role
+	FROM organizations o
+	INNER JOIN organization_members m ON m.org_id = o.id
+	WHERE m.user_id = $1
+	ORDER BY m.created_at, o.id`
+
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []*domain.Organization{}
+
+	for rows.Next() {
+		var org domain.Organization
+
+		err := rows.Scan(
+			&org.ID,
+			&org.Name,
+			&org.CreatedBy,
+			&org.CreatedAt,
+			&org.Version,
+			&org.Role,
+		)
+		if err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, &org)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+const memberQuery = `
+	SELECT m.org_id, m.user_id, u.name, u.email, m.role, m.created_at
+	FROM organization_members m
+	INNER JOIN users u ON u.id = m.user_id`
+
+func (s *organizationRepo) GetMember(ctx context.Context, orgID, userID int64) (*domain.OrganizationMember, error) {
+	query := memberQuery + `
+	WHERE m.org_id = $1 AND m.user_id = $2`
+
+	return s.getMember(ctx, query, orgID, userID)
+}
+
+// GetDefaultMembership returns the user's oldest membership, used for new
+// sessions.
+func (s *organizationRepo) GetDefaultMembership(ctx context.Context, userID int64) (*domain.OrganizationMember, error) {
+	query := memberQuery + `
+	WHERE m.user_id = $1
+	ORDER BY m.created_at, m.org_id
+	LIMIT 1`
+
+	return s.getMember(ctx, query, userID)
+}
+
+func (s *organizationRepo) getMember(ctx context.Context, query string, args ...any) (*domain.OrganizationMember, error) {
+	var member domain.OrganizationMember
+	err := s.db.QueryRow(ctx, query, args...).Scan(
+		&member.OrgID,
+		&member.UserID,
+		&member.Name,
+		&member.Email,
+		&member.Role,
+		&member.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &member, nil
+}
+
+func (s *organizationRepo) GetMembers(ctx context.Context, orgID int64) ([]*domain.OrganizationMember, error) {
+	query := memberQuery + `
+	WHERE m.org_id = $1
+	ORDER BY m.created_at, m.user_id`
+
+	rows, err := s.db.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*domain.OrganizationMember{}
+
+	for rows.Next() {
+		var member domain.OrganizationMember
+
+		err := rows.Scan(
+			&member.OrgID,
+			&member.UserID,
+			&member.Name,
+			&member.Email,
+			&member.Role,
+			&member.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, &member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (s *organizationRepo) AddMember(ctx context.Context, orgID, userID int64, role string) error {
+	query := `
+	INSERT INTO organization_members (org_id, user_id, role)
+	VALUES ($1, $2, $3)`
+
+	_, err := s.db.Exec(ctx, query, orgID, userID, role)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "organization_members_pkey"):
+			return ErrDuplicate
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *organizationRepo) UpdateMemberRole(ctx context.Context, orgID, userID int64, role string) error {
+	query := `
+	UPDATE organization_members
+	SET role = $3
+	WHERE org_id = $1 AND user_id = $2`
+
+	result, err := s.db.Exec(ctx, query, orgID, userID, role)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *organizationRepo) RemoveMember(ctx context.Context, orgID, userID int64) error {
+	query := `
+	DELETE FROM organization_members
+	WHERE org_id = $1 AND user_id = $2`
+
+	result, err := s.db.Exec(ctx, query, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *organizationRepo) CountOwners(ctx context.Context, orgID int64) (int, error) {
+	query := `
+	SELECT COUNT(*)
+	FROM organization_members
+	WHERE org_id = $1 AND role = 'owner'`
+
+	var count int
+	err := s.db.QueryRow(ctx, query, orgID).Scan(&count)
+	return count, err
+}
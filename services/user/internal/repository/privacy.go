@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"microservices/services/user/internal/domain"
+	"strings"
+	"time"
+)
+
+const (
+	PrivacyExport  = "export"
+	PrivacyErasure = "erasure"
+
+	PrivacyPending   = "pending"
+	PrivacyRunning   = "running"
+	PrivacyCompleted = "completed"
+	PrivacyFailed    = "failed"
+)
+
+type privacyRepo struct {
+	db DB
+}
+
+type Privacy interface {
+	Insert(ctx context.Context, request *domain.PrivacyRequest) error
+	GetByID(ctx context.Context, id int64) (*domain.PrivacyRequest, error)
+	GetOpen(ctx context.Context, userID int64, kind string) (*domain.PrivacyRequest, error)
+	ClaimNext(ctx context.Context, staleAfter time.Duration) (*domain.PrivacyRequest, error)
+	UpdateProgress(ctx context.Context, request *domain.PrivacyRequest) error
+	Complete(ctx context.Context, request *domain.PrivacyRequest, archive []byte) error
+	GetArchive(ctx context.Context, id int64, completedAfter time.Time) ([]byte, error)
+	DeleteArchivesCompletedBefore(ctx context.Context, before time.Time) (int64, error)
+	DeleteArchivesForUser(ctx context.Context, userID int64) error
+}
+
+func NewPrivacyRepo(db DB) *privacyRepo {
+	return &privacyRepo{db: db}
+}
+
+const privacyColumns = `id, user_id, requested_by, kind, status, steps, error, attempts, created_at, updated_at, completed_at`
+
+func scanPrivacyRequest(row pgx.Row) (*domain.PrivacyRequest, error) {
+	var request domain.PrivacyRequest
+
+	err := row.Scan(
+		&request.ID,
+		&request.UserID,
+		&request.RequestedBy,
+		&request.Kind,
+		&request.Status,
+		&request.Steps,
+		&request.Error,
+		&request.Attempts,
+		&request.CreatedAt,
+		&request.UpdatedAt,
+		&request.CompletedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &request, nil
+}
+
+// Insert queues a new request. ErrDuplicate means the user already has an
+// open request of the same kind.
+func (s *privacyRepo) Insert(ctx context.Context, request *domain.PrivacyRequest) error {
+	query := `
+	INSERT INTO privacy_requests (user_id, requested_by, kind, steps)
+	VALUES ($1, $2, $3, $4)
+	RETURNING ` + privacyColumns
+
+	steps := request.Steps
+	if steps == nil {
+		steps = map[string]string{}
+	}
+
+	inserted, err := scanPrivacyRequest(s.db.QueryRow(ctx, query, request.UserID, request.RequestedBy, request.Kind, steps))
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "privacy_requests_open_unique"):
+			return ErrDuplicate
+		default:
+			return err
+		}
+	}
+	*request = *inserted
+	return nil
+}
+
+func (s *privacyRepo) GetByID(ctx context.Context, id int64) (*domain.PrivacyRequest, error) {
+	query := `
+	SELECT ` + privacyColumns + `
+	FROM privacy_requests
+	WHERE id = $1`
+
+	return scanPrivacyRequest(s.db.QueryRow(ctx, query, id))
+}
+
+func (s *privacyRepo) GetOpen(ctx context.Context, userID int64, kind string) (*domain.PrivacyRequest, error) {
+	query := `
+	SELECT ` + privacyColumns + `
+	FROM privacy_requests
+	WHERE user_id = $1 AND kind = $2 AND status IN ('pending', 'running')`
+
+	return scanPrivacyRequest(s.db.QueryRow(ctx, query, userID, kind))
+}
+
+// ClaimNext marks the oldest pending request as running and returns it.
+// Requests left running for longer than staleAfter, e.g. by a process that
+// died, are claimed again.
+func (s *privacyRepo) ClaimNext(ctx context.Context, staleAfter time.Duration) (*domain.PrivacyRequest, error) {
+	query := `
+	UPDATE privacy_requests
+	SET status = 'running', attempts = attempts + 1, updated_at = NOW()
+	WHERE id = (
+		SELECT id FROM privacy_requests
+		WHERE status = 'pending' OR (status = 'running' AND updated_at < $1)
+		ORDER BY id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + privacyColumns
+
+	return scanPrivacyRequest(s.db.QueryRow(ctx, query, time.Now().Add(-staleAfter)))
+}
+
+// UpdateProgress saves the status, steps and error of the request.
+func (s *privacyRepo) UpdateProgress(ctx context.Context, request *domain.PrivacyRequest) error {
+	query := `
+	UPDATE privacy_requests
+	SET status = $2, steps = $3, error = $4, updated_at = NOW()
+	WHERE id = $1
+	RETURNING updated_at`
+
+	err := s.db.QueryRow(ctx, query, request.ID, request.Status, request.Steps, request.Error).Scan(&request.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Complete marks the request completed, storing the archive of an export.
+func (s *privacyRepo) Complete(ctx context.Context, request *domain.PrivacyRequest, archive []byte) error {
+	query := `
+	UPDATE privacy_requests
+	SET status = 'completed', steps = $2, error = '', archive = $3, updated_at = NOW(), completed_at = NOW()
+	WHERE id = $1
+	RETURNING status, error, updated_at, completed_at`
+
+	var archiveArg any
+	if archive != nil {
+		archiveArg = string(archive)
+	}
+
+	err := s.db.QueryRow(ctx, query, request.ID, request.Steps, archiveArg).Scan(
+		&request.Status,
+		&request.Error,
+		&request.UpdatedAt,
+		&request.CompletedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// GetArchive returns the archive of an export completed after
+// completedAfter; older archives are treated as gone.
+func (s *privacyRepo) GetArchive(ctx context.Context, id int64, completedAfter time.Time) ([]byte, error) {
+	query := `
+	SELECT archive::text
+	FROM privacy_requests
+	WHERE id = $1 AND archive IS NOT NULL AND completed_at > $2`
+
+	var archive string
+	err := s.db.QueryRow(ctx, query, id, completedAfter).Scan(&archive)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return []byte(archive), nil
+}
+
+// DeleteArchivesCompletedBefore drops the archives of exports completed
+// before the given time and returns how many there were.
+func (s *privacyRepo) DeleteArchivesCompletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+	UPDATE privacy_requests
+	SET archive = NULL
+	WHERE archive IS NOT NULL AND completed_at <= $1`
+
+	result, err := s.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// DeleteArchivesForUser drops the exports made for the user, which would
+// otherwise outlive an erasure.
+func (s *privacyRepo) DeleteArchivesForUser(ctx context.Context, userID int64) error {
+	query := `
+	UPDATE privacy_requests
+	SET archive = NULL
+	WHERE user_id = $1 AND archive IS NOT NULL`
+
+	_, err := s.db.Exec(ctx, query, userID)
+	return err
+}
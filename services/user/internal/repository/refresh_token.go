@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"microservices/services/user/internal/domain"
+)
+
+type refreshTokenRepo struct {
+	db DB
+}
+
+type RefreshToken interface {
+	Insert(ctx context.Context, token *domain.RefreshToken) error
+	GetByHash(ctx context.Context, hash []byte) (*domain.RefreshToken, error)
+	MarkUsed(ctx context.Context, hash []byte) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID int64) error
+}
+
+func NewRefreshTokenRepo(db DB) *refreshTokenRepo {
+	return &refreshTokenRepo{db: db}
+}
+
+func (s *refreshTokenRepo) Insert(ctx context.Context, token *domain.RefreshToken) error {
+	query := `
+	INSERT INTO refresh_tokens (hash, user_id, family_id, expires_at)
+	VALUES ($1, $2, $3, $4)
+	RETURNING created_at`
+
+	args := []any{token.Hash, token.UserID, token.FamilyID, token.ExpiresAt}
+
+	return s.db.QueryRow(ctx, query, args...).Scan(&token.CreatedAt)
+}
+
+func (s *refreshTokenRepo) GetByHash(ctx context.Context, hash []byte) (*domain.RefreshToken, error) {
+	query := `
+	SELECT hash, user_id, family_id, expires_at, created_at, used_at, revoked_at
+	FROM refresh_tokens
+	WHERE hash = $1`
+
+	var token domain.RefreshToken
+	err := s.db.QueryRow(ctx, query, hash).Scan(
+		&token.Hash,
+		&token.UserID,
+		&token.FamilyID,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.UsedAt,
+		&token.RevokedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &token, nil
+}
+
+// MarkUsed returns ErrEditConflict when the token has already been used or
+// revoked, so two concurrent refreshes cannot both rotate the same token.
+func (s *refreshTokenRepo) MarkUsed(ctx context.Context, hash []byte) error {
+	query := `
+	UPDATE refresh_tokens
+	SET used_at = NOW()
+	WHERE hash = $1 AND used_at IS NULL AND revoked_at IS NULL`
+
+	result, err := s.db.Exec(ctx, query, hash)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrEditConflict
+	}
+	return nil
+}
+
+func (s *refreshTokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = NOW()
+	WHERE family_id = $1 AND revoked_at IS NULL`
+
+	_, err := s.db.Exec(ctx, query, familyID)
+	return err
+}
+
+func (s *refreshTokenRepo) RevokeAllForUser(ctx context.Context, userID int64) error {
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = NOW()
+	WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := s.db.Exec(ctx, query, userID)
+	return err
+}
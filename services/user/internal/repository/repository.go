@@ -3,9 +3,11 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"microservices-go/services/user/internal/domain"
+	"microservices/services/user/internal/domain"
 	"strings"
 	"time"
 )
@@ -16,16 +18,94 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// DB is what the repositories need from a connection: the pool, or a
+// transaction when several changes must succeed or fail together.
+type DB interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type Repositories struct {
+	db DB
+
+	Users         User
+	RefreshTokens RefreshToken
+	Roles         Role
+	UserTokens    UserToken
+	LoginFailures LoginFailure
+	MFA           MFA
+	Audit         Audit
+	APIKeys       APIKey
+	Sessions      Session
+	OIDC          OIDC
+	Privacy       Privacy
+	Organizations Organization
+	Invitations   Invitation
+}
+
+func New(db *pgxpool.Pool) Repositories {
+	return newRepositories(db)
+}
+
+func newRepositories(db DB) Repositories {
+	return Repositories{
+		db:            db,
+		Users:         NewUserRepo(db),
+		RefreshTokens: NewRefreshTokenRepo(db),
+		Roles:         NewRoleRepo(db),
+		UserTokens:    NewUserTokenRepo(db),
+		LoginFailures: NewLoginFailureRepo(db),
+		MFA:           NewMFARepo(db),
+		Audit:         NewAuditRepo(db),
+		APIKeys:       NewAPIKeyRepo(db),
+		Sessions:      NewSessionRepo(db),
+		OIDC:          NewOIDCRepo(db),
+		Privacy:       NewPrivacyRepo(db),
+		Organizations: NewOrganizationRepo(db),
+		Invitations:   NewInvitationRepo(db),
+	}
+}
+
+// Transaction runs fn with repositories that share one transaction, which
+// is committed if fn returns nil and rolled back otherwise. Inside a
+// transaction, Begin in the repositories starts a savepoint.
+func (r Repositories) Transaction(ctx context.Context, fn func(repos Repositories) error) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = fn(newRepositories(tx))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
 type userRepo struct {
-	db *pgxpool.Pool
+	db DB
 }
 
 type User interface {
 	Insert(ctx context.Context, user *domain.User) error
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	GetByID(ctx context.Context, id int64) (*domain.User, error)
+	GetByIDs(ctx context.Context, ids []int64) ([]*domain.User, error)
+	GetAll(ctx context.Context, search string, filters Filters) ([]*domain.User, error)
+	UpdatePasswordHash(ctx context.Context, id int64, passwordHash string) error
+	SetDisabled(ctx context.Context, id int64, disabled bool) error
+	RequirePasswordReset(ctx context.Context, id int64) error
+	MarkEmailVerified(ctx context.Context, id int64) error
+	Update(ctx context.Context, user *domain.User) error
+	Delete(ctx context.Context, id int64) error
+	Anonymise(ctx context.Context, id int64) error
 }
 
-func NewUserRepo(db *pgxpool.Pool) *userRepo {
+func NewUserRepo(db DB) *userRepo {
 	return &userRepo{db: db}
 }
 
@@ -33,11 +113,11 @@ func (s *userRepo) Insert(ctx context.Context, user *domain.User) error {
 	query := `
 	INSERT INTO users (name, email, password_hash)
 	VALUES ($1, $2, $3)
-	RETURNING id, created_at`
+	RETURNING id, created_at, version`
 
 	args := []any{user.Name, user.Email, user.HashPassword}
 
-	err := s.db.QueryRow(ctx, query, args...).Scan(&user.ID, &user.CreatedAt)
+	err := s.db.QueryRow(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
 	if err != nil {
 		switch {
 		case strings.Contains(err.Error(), "users_email_unique"):
@@ -51,7 +131,7 @@ func (s *userRepo) Insert(ctx context.Context, user *domain.User) error {
 
 func (s *userRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
 	query := `
-	SELECT id, name, email, password_hash, created_at
+	SELECT id, name, email, password_hash, created_at, email_verified_at, version, disabled_at, password_reset_required
 	FROM users
 	WHERE email = $1`
 
@@ -62,6 +142,10 @@ func (s *userRepo) GetByEmail(ctx context.Context, email string) (*domain.User,
 		&user.Email,
 		&user.HashPassword,
 		&user.CreatedAt,
+		&user.EmailVerifiedAt,
+		&user.Version,
+		&user.DisabledAt,
+		&user.PasswordResetRequired,
 	)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -77,3 +161,263 @@ func (s *userRepo) GetByEmail(ctx context.Context, email string) (*domain.User,
 	}
 	return &user, nil
 }
+
+func (s *userRepo) GetByID(ctx context.Context, id int64) (*domain.User, error) {
+	query := `
+	SELECT id, name, email, password_hash, created_at, email_verified_at, version, disabled_at, password_reset_required
+	FROM users
+	WHERE id = $1`
+
+	var user domain.User
+	err := s.db.QueryRow(ctx, query, id).Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.HashPassword,
+		&user.CreatedAt,
+		&user.EmailVerifiedAt,
+		&user.Version,
+		&user.DisabledAt,
+		&user.PasswordResetRequired,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+func (s *userRepo) GetByIDs(ctx context.Context, ids []int64) ([]*domain.User, error) {
+	query := `
+	SELECT id, name, email, password_hash, created_at, email_verified_at, version, disabled_at, password_reset_required
+	FROM users
+	WHERE id = ANY($1)
+	ORDER BY id`
+
+	rows, err := s.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	return scanUsers(rows)
+}
+
+// GetAll pages through all users whose name or email contains search,
+// ignoring case; an empty search matches everyone.
+func (s *userRepo) GetAll(ctx context.Context, search string, filters Filters) ([]*domain.User, error) {
+	query := fmt.Sprintf(`
+	SELECT id, name, email, password_hash, created_at, email_verified_at, version, disabled_at, password_reset_required
+	FROM users
+	WHERE (strpos(lower(name), lower($1)) > 0 OR strpos(lower(email), lower($1)) > 0 OR $1 = '')
+	ORDER BY %s %s, id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	rows, err := s.db.Query(ctx, query, search, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, err
+	}
+	return scanUsers(rows)
+}
+
+func scanUsers(rows pgx.Rows) ([]*domain.User, error) {
+	defer rows.Close()
+
+	users := []*domain.User{}
+
+	for rows.Next() {
+		var user domain.User
+
+		err := rows.Scan(
+			&user.ID,
+			&user.Name,
+			&user.Email,
+			&user.HashPassword,
+			&user.CreatedAt,
+			&user.EmailVerifiedAt,
+			&user.Version,
+			&user.DisabledAt,
+			&user.PasswordResetRequired,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s *userRepo) UpdatePasswordHash(ctx context.Context, id int64, passwordHash string) error {
+	query := `
+	UPDATE users
+	SET password_hash = $2, password_reset_required = false, version = version + 1
+	WHERE id = $1`
+
+	result, err := s.db.Exec(ctx, query, id, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *userRepo) SetDisabled(ctx context.Context, id int64, disabled bool) error {
+	query := `
+	UPDATE users
+	SET disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, NOW()) END, version = version + 1
+	WHERE id = $1`
+
+	result, err := s.db.Exec(ctx, query, id, disabled)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *userRepo) RequirePasswordReset(ctx context.Context, id int64) error {
+	query := `
+	UPDATE users
+	SET password_reset_required = true, version = version + 1
+	WHERE id = $1`
+
+	result, err := s.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *userRepo) MarkEmailVerified(ctx context.Context, id int64) error {
+	query := `
+	UPDATE users
+	SET email_verified_at = NOW(), version = version + 1
+	WHERE id = $1 AND email_verified_at IS NULL`
+
+	_, err := s.db.Exec(ctx, query, id)
+	return err
+}
+
+// Update writes all mutable fields of user, provided the row still has the
+// version the caller read; otherwise ErrEditConflict is returned.
+func (s *userRepo) Update(ctx context.Context, user *domain.User) error {
+	query := `
+	UPDATE users
+	SET name = $1, email = $2, password_hash = $3, email_verified_at = $4, version = version + 1
+	WHERE id = $5 AND version = $6
+	RETURNING version`
+
+	args := []any{
+		user.Name,
+		user.Email,
+		user.HashPassword,
+		user.EmailVerifiedAt,
+		user.ID,
+		user.Version,
+	}
+
+	err := s.db.QueryRow(ctx, query, args...).Scan(&user.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrEditConflict
+		case strings.Contains(err.Error(), "users_email_unique"):
+			return ErrDuplicate
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *userRepo) Delete(ctx context.Context, id int64) error {
+	query := `
+	DELETE FROM users
+	WHERE id = $1`
+
+	result, err := s.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Anonymise replaces the personal data held in the users row with
+// placeholders, disables the account and removes everything that could be
+// used to sign in as the user or that describes their devices. The row
+// itself is kept so that references such as the audit log stay valid.
+func (s *userRepo) Anonymise(ctx context.Context, id int64) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var email string
+	err = tx.QueryRow(ctx, `SELECT email FROM users WHERE id = $1 FOR UPDATE`, id).Scan(&email)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+	UPDATE users
+	SET name = 'Deleted user', email = 'deleted-' || id || '@erased.invalid', password_hash = '',
+		email_verified_at = NULL, password_reset_required = false,
+		disabled_at = COALESCE(disabled_at, NOW()), version = version + 1
+	WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		`DELETE FROM refresh_tokens WHERE user_id = $1`,
+		`DELETE FROM sessions WHERE user_id = $1`,
+		`DELETE FROM api_keys WHERE user_id = $1`,
+		`DELETE FROM user_tokens WHERE user_id = $1`,
+		`DELETE FROM user_identities WHERE user_id = $1`,
+		`DELETE FROM mfa_recovery_codes WHERE user_id = $1`,
+		`DELETE FROM user_totp WHERE user_id = $1`,
+	} {
+		_, err = tx.Exec(ctx, query, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM login_failures WHERE key = $1`, "email:"+strings.ToLower(email))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM invitations WHERE lower(email) = lower($1)`, email)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
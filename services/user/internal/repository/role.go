@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"microservices/services/user/internal/domain"
+)
+
+type roleRepo struct {
+	db DB
+}
+
+type Role interface {
+	GetAll(ctx context.Context) ([]*domain.Role, error)
+	GetAllForUser(ctx context.Context, userID int64) ([]string, error)
+	GetPermissionsForUser(ctx context.Context, userID int64) ([]string, error)
+	AddForUser(ctx context.Context, userID int64, role string) error
+	RemoveForUser(ctx context.Context, userID int64, role string) error
+}
+
+func NewRoleRepo(db DB) *roleRepo {
+	return &roleRepo{db: db}
+}
+
+func (s *roleRepo) GetAll(ctx context.Context) ([]*domain.Role, error) {
+	query := `
+	SELECT roles.id, roles.name, COALESCE(array_agg(permissions.code ORDER BY permissions.code) FILTER (WHERE permissions.code IS NOT NULL), '{}')
+	FROM roles
+	LEFT JOIN roles_permissions ON roles_permissions.role_id = roles.id
+	LEFT JOIN permissions ON permissions.id = roles_permissions.permission_id
+	GROUP BY roles.id
+	ORDER BY roles.id`
+
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*domain.Role{}
+	for rows.Next() {
+		var role domain.Role
+		if err := rows.Scan(&role.ID, &role.Name, &role.Permissions); err != nil {
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (s *roleRepo) GetAllForUser(ctx context.Context, userID int64) ([]string, error) {
+	query := `
+	SELECT roles.name
+	FROM roles
+	INNER JOIN users_roles ON users_roles.role_id = roles.id
+	WHERE users_roles.user_id = $1
+	ORDER BY roles.name`
+
+	return s.queryStrings(ctx, query, userID)
+}
+
+func (s *roleRepo) GetPermissionsForUser(ctx context.Context, userID int64) ([]string, error) {
+	query := `
+	SELECT DISTINCT permissions.code
+	FROM permissions
+	INNER JOIN roles_permissions ON roles_permissions.permission_id = permissions.id
+	INNER JOIN users_roles ON users_roles.role_id = roles_permissions.role_id
+	WHERE users_roles.user_id = $1
+	ORDER BY permissions.code`
+
+	return s.queryStrings(ctx, query, userID)
+}
+
+// AddForUser returns ErrRecordNotFound when the role does not exist.
+func (s *roleRepo) AddForUser(ctx context.Context, userID int64, role string) error {
+	query := `
+	INSERT INTO users_roles (user_id, role_id)
+	SELECT $1, roles.id FROM roles WHERE roles.name = $2
+	ON CONFLICT DO NOTHING`
+
+	result, err := s.db.Exec(ctx, query, userID, role)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		var exists bool
+		err = s.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)`, role).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrRecordNotFound
+		}
+	}
+	return nil
+}
+
+func (s *roleRepo) RemoveForUser(ctx context.Context, userID int64, role string) error {
+	query := `
+	DELETE FROM users_roles
+	USING roles
+	WHERE users_roles.role_id = roles.id AND users_roles.user_id = $1 AND roles.name = $2`
+
+	result, err := s.db.Exec(ctx, query, userID, role)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *roleRepo) queryStrings(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"microservices/services/user/internal/domain"
+	"time"
+)
+
+type sessionRepo struct {
+	db DB
+}
+
+type Session interface {
+	Insert(ctx context.Context, session *domain.Session) error
+	GetByID(ctx context.Context, id int64) (*domain.Session, error)
+	GetByFamilyID(ctx context.Context, familyID string) (*domain.Session, error)
+	GetActiveForUser(ctx context.Context, userID int64, seenSince time.Time) ([]*domain.Session, error)
+	Touch(ctx context.Context, id int64) error
+	SetOrganization(ctx context.Context, id int64, orgID *int64) error
+	Revoke(ctx context.Context, id, userID int64) (string, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID int64) error
+}
+
+func NewSessionRepo(db DB) *sessionRepo {
+	return &sessionRepo{db: db}
+}
+
+func (s *sessionRepo) Insert(ctx context.Context, session *domain.Session) error {
+	query := `
+	INSERT INTO sessions (user_id, family_id, user_agent, ip, org_id)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at, last_seen_at`
+
+	args := []any{session.UserID, session.FamilyID, session.UserAgent, session.IP, session.OrgID}
+
+	return s.db.QueryRow(ctx, query, args...).Scan(&session.ID, &session.CreatedAt, &session.LastSeenAt)
+}
+
+func (s *sessionRepo) GetByID(ctx context.Context, id int64) (*domain.Session, error) {
+	query := `
+	SELECT id, user_id, family_id, user_agent, ip, created_at, last_seen_at, revoked_at, org_id
+	FROM sessions
+	WHERE id = $1`
+
+	return s.get(ctx, query, id)
+}
+
+func (s *sessionRepo) GetByFamilyID(ctx context.Context, familyID string) (*domain.Session, error) {
+	query := `
+	SELECT id, user_id, family_id, user_agent, ip, created_at, last_seen_at, revoked_at, org_id
+	FROM sessions
+	WHERE family_id = $1`
+
+	return s.get(ctx, query, familyID)
+}
+
+func (s *sessionRepo) get(ctx context.Context, query string, arg any) (*domain.Session, error) {
+	var session domain.Session
+	err := s.db.QueryRow(ctx, query, arg).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.FamilyID,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.RevokedAt,
+		&session.OrgID,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &session, nil
+}
+
+// GetActiveForUser returns the sessions that are not revoked and were used
+// after seenSince, most recently used first.
+func (s *sessionRepo) GetActiveForUser(ctx context.Context, userID int64, seenSince time.Time) ([]*domain.Session, error) {
+	query := `
+	SELECT id, user_id, family_id, user_agent, ip, created_at, last_seen_at, revoked_at, org_id
+	FROM sessions
+	WHERE user_id = $1 AND revoked_at IS NULL AND last_seen_at > $2
+	ORDER BY last_seen_at DESC, id DESC`
+
+	rows, err := s.db.Query(ctx, query, userID, seenSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*domain.Session{}
+
+	for rows.Next() {
+		var session domain.Session
+
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.FamilyID,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+			&session.RevokedAt,
+			&session.OrgID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (s *sessionRepo) Touch(ctx context.Context, id int64) error {
+	query := `
+	UPDATE sessions
+	SET last_seen_at = NOW()
+	WHERE id = $1`
+
+	_, err := s.db.Exec(ctx, query, id)
+	return err
+}
+
+// Revoke ends a session of the given user and returns its refresh token
+// family, or ErrRecordNotFound if the user has no such active session.
+func (s *sessionRepo) Revoke(ctx context.Context, id, userID int64) (string, error) {
+	query := `
+	UPDATE sessions
+	SET revoked_at = NOW()
+	WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	RETURNING family_id`
+
+	var familyID string
+	err := s.db.QueryRow(ctx, query, id, userID).Scan(&familyID)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+	return familyID, nil
+}
+
+func (s *sessionRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `
+	UPDATE sessions
+	SET revoked_at = NOW()
+	WHERE family_id = $1 AND revoked_at IS NULL`
+
+	_, err := s.db.Exec(ctx, query, familyID)
+	return err
+}
+
+func (s *sessionRepo) RevokeAllForUser(ctx context.Context, userID int64) error {
+	query := `
+	UPDATE sessions
+	SET revoked_at = NOW()
+	WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := s.db.Exec(ctx, query, userID)
+	return err
+}
+
+func (s *sessionRepo) SetOrganization(ctx context.Context, id int64, orgID *int64) error {
+	query := `
+	UPDATE sessions
+	SET org_id = $2
+	WHERE id = $1 AND revoked_at IS NULL`
+
+	result, err := s.db.Exec(ctx, query, id, orgID)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"microservices/services/user/internal/domain"
+)
+
+const (
+	ScopeEmailVerification = "email-verification"
+	ScopePasswordReset     = "password-reset"
+	ScopeAccountUnlock     = "account-unlock"
+	ScopeMFAChallenge      = "mfa-challenge"
+	ScopeMagicLink         = "magic-link"
+)
+
+type userTokenRepo struct {
+	db DB
+}
+
+type UserToken interface {
+	Insert(ctx context.Context, token *domain.UserToken) error
+	Consume(ctx context.Context, scope string, hash []byte) (int64, error)
+	Lookup(ctx context.Context, scope string, hash []byte) (int64, error)
+	GetLatestForUser(ctx context.Context, scope string, userID int64) (*domain.UserToken, error)
+	DeleteAllForUser(ctx context.Context, scope string, userID int64) error
+}
+
+func NewUserTokenRepo(db DB) *userTokenRepo {
+	return &userTokenRepo{db: db}
+}
+
+func (s *userTokenRepo) Insert(ctx context.Context, token *domain.UserToken) error {
+	query := `
+	INSERT INTO user_tokens (hash, user_id, scope, expires_at)
+	VALUES ($1, $2, $3, $4)
+	RETURNING created_at`
+
+	args := []any{token.Hash, token.UserID, token.Scope, token.ExpiresAt}
+
+	return s.db.QueryRow(ctx, query, args...).Scan(&token.CreatedAt)
+}
+
+// Consume deletes an unexpired token and returns the ID of the user it was
+// issued to. Deleting and reading in one statement keeps tokens single-use.
+func (s *userTokenRepo) Consume(ctx context.Context, scope string, hash []byte) (int64, error) {
+	query := `
+	DELETE FROM user_tokens
+	WHERE hash = $1 AND scope = $2 AND expires_at > NOW()
+	RETURNING user_id`
+
+	var userID int64
+	err := s.db.QueryRow(ctx, query, hash, scope).Scan(&userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+	return userID, nil
+}
+
+// Lookup returns the owner of a live token without using it up.
+func (s *userTokenRepo) Lookup(ctx context.Context, scope string, hash []byte) (int64, error) {
+	query := `
+	SELECT user_id
+	FROM user_tokens
+	WHERE hash = $1 AND scope = $2 AND expires_at > NOW()`
+
+	var userID int64
+	err := s.db.QueryRow(ctx, query, hash, scope).Scan(&userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+	return userID, nil
+}
+
+func (s *userTokenRepo) GetLatestForUser(ctx context.Context, scope string, userID int64) (*domain.UserToken, error) {
+	query := `
+	SELECT hash, user_id, scope, expires_at, created_at
+	FROM user_tokens
+	WHERE user_id = $1 AND scope = $2
+	ORDER BY created_at DESC
+	LIMIT 1`
+
+	var token domain.UserToken
+	err := s.db.QueryRow(ctx, query, userID, scope).Scan(
+		&token.Hash,
+		&token.UserID,
+		&token.Scope,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &token, nil
+}
+
+func (s *userTokenRepo) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	query := `
+	DELETE FROM user_tokens
+	WHERE user_id = $1 AND scope = $2`
+
+	_, err := s.db.Exec(ctx, query, userID, scope)
+	return err
+}
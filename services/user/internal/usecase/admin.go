@@ -0,0 +1,114 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"microservices/pkg/validator"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+)
+
+// Audit actions recorded in the audit log.
+const (
+	AuditUserDisabled        = "user.disabled"
+	AuditUserEnabled         = "user.enabled"
+	AuditPasswordResetForced = "user.password_reset_forced"
+	AuditRoleAssigned        = "role.assigned"
+	AuditRoleRevoked         = "role.revoked"
+)
+
+// DisableUser blocks sign-in for the account and revokes its refresh tokens.
+// Administrators cannot disable themselves.
+func (s *service) DisableUser(ctx context.Context, actorID, userID int64) error {
+	if actorID == userID {
+		return ErrFailedValidation
+	}
+
+	return s.inTx(ctx, func(tx *service) error {
+		err := tx.setDisabled(ctx, userID, true)
+		if err != nil {
+			return err
+		}
+
+		err = tx.revokeAllSessions(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		return tx.recordAudit(ctx, actorID, userID, AuditUserDisabled, nil)
+	})
+}
+
+func (s *service) EnableUser(ctx context.Context, actorID, userID int64) error {
+	return s.inTx(ctx, func(tx *service) error {
+		err := tx.setDisabled(ctx, userID, false)
+		if err != nil {
+			return err
+		}
+
+		return tx.recordAudit(ctx, actorID, userID, AuditUserEnabled, nil)
+	})
+}
+
+// ForcePasswordReset blocks sign-in until the user has chosen a new password
+// through the emailed reset token, and ends all existing sessions.
+func (s *service) ForcePasswordReset(ctx context.Context, actorID, userID int64) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.inTx(ctx, func(tx *service) error {
+		err := tx.repo.RequirePasswordReset(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.revokeAllSessions(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		return tx.recordAudit(ctx, actorID, userID, AuditPasswordResetForced, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.sendPasswordReset(ctx, user,
+		"An administrator requires you to choose a new password before you can sign in again. "+
+			"Send the token below together with your new password to POST /v1/user/password/reset:",
+		"If the token expires, request a new one through POST /v1/user/password/forgot.")
+}
+
+func (s *service) GetAuditLog(ctx context.Context, userID int64, filters repository.Filters) ([]*domain.AuditEvent, error) {
+	v := validator.New()
+	if repository.ValidateFilters(v, filters); !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	return s.audit.GetAllForUser(ctx, userID, filters)
+}
+
+func (s *service) setDisabled(ctx context.Context, userID int64, disabled bool) error {
+	err := s.repo.SetDisabled(ctx, userID, disabled)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) recordAudit(ctx context.Context, actorID, userID int64, action string, details map[string]any) error {
+	event := domain.AuditEvent{
+		ActorID:      actorID,
+		TargetUserID: userID,
+		Action:       action,
+		Details:      details,
+	}
+	return s.audit.Insert(ctx, &event)
+}
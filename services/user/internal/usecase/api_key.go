@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"microservices/pkg/token"
+	"microservices/pkg/validator"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+	"time"
+)
+
+// CreateAPIKey issues a new key for the user. The plaintext key is only
+// returned here; afterwards the key is identified by its prefix.
+func (s *service) CreateAPIKey(ctx context.Context, userID int64, input CreateAPIKeyDTO) (string, *domain.APIKey, error) {
+	identity, err := s.identity(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	v := validator.New()
+	v.Check(input.Name != "", "name", "must be provided")
+	v.Check(len(input.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(len(input.Scopes) > 0, "scopes", "must contain at least one permission")
+	v.Check(validator.Unique(input.Scopes), "scopes", "must not contain duplicate values")
+	for _, scope := range input.Scopes {
+		v.Check(identity.HasPermission(scope), "scopes", "must only contain permissions you hold")
+	}
+	if input.ExpiresAt != nil {
+		v.Check(input.ExpiresAt.After(time.Now()), "expires_at", "must be in the future")
+	}
+	if !v.Valid() {
+		return "", nil, ErrFailedValidation
+	}
+
+	var orgID *int64
+	if input.OrgID != 0 {
+		_, err := s.membership(ctx, input.OrgID, userID)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrRecordNotFound):
+				return "", nil, ErrFailedValidation
+			default:
+				return "", nil, err
+			}
+		}
+		orgID = &input.OrgID
+	}
+
+	plaintext, prefix, hash, err := token.NewAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := domain.APIKey{
+		UserID:    userID,
+		Name:      input.Name,
+		Prefix:    prefix,
+		Hash:      hash,
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+		OrgID:     orgID,
+	}
+
+	err = s.apiKeys.Insert(ctx, &key)
+	if err != nil {
+		return "", nil, err
+	}
+	return plaintext, &key, nil
+}
+
+func (s *service) ListAPIKeys(ctx context.Context, userID int64) ([]*domain.APIKey, error) {
+	return s.apiKeys.GetAllForUser(ctx, userID)
+}
+
+func (s *service) RevokeAPIKey(ctx context.Context, userID, keyID int64) error {
+	err := s.apiKeys.Delete(ctx, keyID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveAPIKey returns the identity an API key acts as: its owner, with the
+// permissions the owner currently holds narrowed down to the key's scopes,
+// in the organization the key was created in. Keys stop working once their
+// owner leaves that organization.
+func (s *service) ResolveAPIKey(ctx context.Context, plaintext string) (token.Identity, error) {
+	prefix, ok := token.ParseAPIKey(plaintext)
+	if !ok {
+		return token.Identity{}, ErrInvalidToken
+	}
+
+	key, err := s.apiKeys.GetByPrefix(ctx, prefix)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return token.Identity{}, ErrInvalidToken
+		default:
+			return token.Identity{}, err
+		}
+	}
+
+	if subtle.ConstantTimeCompare(key.Hash, token.HashOpaqueToken(plaintext)) != 1 {
+		return token.Identity{}, ErrInvalidToken
+	}
+
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return token.Identity{}, ErrInvalidToken
+	}
+
+	identity, err := s.identity(ctx, key.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound), errors.Is(err, ErrAccountDisabled):
+			return token.Identity{}, ErrInvalidToken
+		default:
+			return token.Identity{}, err
+		}
+	}
+
+	var permissions []string
+	for _, scope := range key.Scopes {
+		if identity.HasPermission(scope) {
+			permissions = append(permissions, scope)
+		}
+	}
+	identity.Permissions = permissions
+
+	if key.OrgID != nil {
+		err = s.withOrganization(ctx, &identity, *key.OrgID)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrRecordNotFound):
+				return token.Identity{}, ErrInvalidToken
+			default:
+				return token.Identity{}, err
+			}
+		}
+	}
+
+	if err := s.apiKeys.TouchLastUsed(ctx, key.ID); err != nil {
+		log.Printf("record use of api key %d: %v", key.ID, err)
+	}
+	return identity, nil
+}
@@ -0,0 +1,215 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"microservices/pkg/mailer"
+	"microservices/pkg/token"
+	"microservices/pkg/validator"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+	"net/url"
+	"time"
+)
+
+// InviteMember invites an email address to the organization and mails the
+// invitation token. Inviting an address again replaces its open invitation.
+func (s *service) InviteMember(ctx context.Context, actorID, orgID int64, input InviteMemberDTO) (*domain.Invitation, error) {
+	v := validator.New()
+	validateEmail(v, input.Email)
+	validateOrgRole(v, input.Role)
+	if !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	err := s.checkCanManage(ctx, actorID, orgID, "", input.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetByEmail(ctx, input.Email)
+	switch {
+	case err == nil:
+		_, err = s.orgs.GetMember(ctx, orgID, user.ID)
+		if err == nil {
+			return nil, ErrDuplicate
+		}
+		if !errors.Is(err, repository.ErrRecordNotFound) {
+			return nil, err
+		}
+	case !errors.Is(err, repository.ErrRecordNotFound):
+		return nil, err
+	}
+
+	org, err := s.orgs.GetByID(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	inviter, err := s.getUser(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, hash, err := token.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation := domain.Invitation{
+		OrgID:     orgID,
+		Email:     input.Email,
+		Role:      input.Role,
+		TokenHash: hash,
+		InvitedBy: &actorID,
+		ExpiresAt: time.Now().Add(s.config.InvitationTTL),
+	}
+
+	err = s.invitations.Insert(ctx, &invitation)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.sendInvitation(ctx, &invitation, org, inviter, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ListInvitations returns the organization's open invitations to those who
+// may manage its members.
+func (s *service) ListInvitations(ctx context.Context, actorID, orgID int64) ([]*domain.Invitation, error) {
+	err := s.checkCanManage(ctx, actorID, orgID, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return s.invitations.GetAllPending(ctx, orgID)
+}
+
+func (s *service) RevokeInvitation(ctx context.Context, actorID, orgID, invitationID int64) error {
+	// Outsiders learn nothing about the organization's invitations.
+	_, err := s.membership(ctx, orgID, actorID)
+	if err != nil {
+		return err
+	}
+
+	invitation, err := s.invitations.GetPendingByID(ctx, orgID, invitationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	err = s.checkCanManage(ctx, actorID, orgID, "", invitation.Role)
+	if err != nil {
+		return err
+	}
+
+	err = s.invitations.Delete(ctx, orgID, invitationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// AcceptInvitation adds the invited address to the organization. An
+// existing account with that address is linked as it is; otherwise an
+// account is signed up with the given name and password, whether or not
+// open sign-up is enabled. The invitation is only used up once the account
+// is in place, so a rejected password can be corrected and sent again.
+func (s *service) AcceptInvitation(ctx context.Context, input AcceptInvitationDTO) (*domain.Organization, error) {
+	invitation, err := s.invitations.GetPending(ctx, token.HashOpaqueToken(input.Token))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return nil, ErrInvalidToken
+		default:
+			return nil, err
+		}
+	}
+
+	// The account, the used-up invitation and the membership are stored
+	// together or not at all, so a failure leaves the invitation open.
+	var user *domain.User
+	err = s.inTx(ctx, func(tx *service) error {
+		var err error
+		user, err = tx.repo.GetByEmail(ctx, invitation.Email)
+		switch {
+		case err == nil:
+			if user.DisabledAt != nil {
+				return ErrAccountDisabled
+			}
+
+			// Receiving the invitation proves the user controls the address.
+			err = tx.repo.MarkEmailVerified(ctx, user.ID)
+			if err != nil {
+				return err
+			}
+		case errors.Is(err, repository.ErrRecordNotFound):
+			user, err = tx.signUp(ctx, UserSignUpDTO{
+				Name:         input.Name,
+				Email:        invitation.Email,
+				HashPassword: input.Password,
+			}, true)
+			if err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		err = tx.invitations.MarkAccepted(ctx, invitation.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				return ErrInvalidToken
+			default:
+				return err
+			}
+		}
+
+		// A failed insert would abort the transaction, so an existing
+		// membership is looked up rather than caught as a duplicate.
+		_, err = tx.orgs.GetMember(ctx, invitation.OrgID, user.ID)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return tx.orgs.AddMember(ctx, invitation.OrgID, user.ID, invitation.Role)
+		default:
+			return err
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetOrganization(ctx, user.ID, invitation.OrgID)
+}
+
+func (s *service) sendInvitation(ctx context.Context, invitation *domain.Invitation, org *domain.Organization, inviter *domain.User, plaintext string) error {
+	instructions := fmt.Sprintf("send the token below to POST /v1/invitations/accept, with a name and password if you have no account yet:\n\n"+
+		"{\"token\": \"%s\"}", plaintext)
+	if s.config.InvitationURL != "" {
+		instructions = fmt.Sprintf("open the link below:\n\n%s?token=%s", s.config.InvitationURL, url.QueryEscape(plaintext))
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You have been invited to %s", org.Name),
+		Body: fmt.Sprintf("Hi,\n\n"+
+			"%s has invited you to join %s as %s. To accept, %s\n\n"+
+			"The invitation can be used once and expires in %s. If you were not expecting it you can ignore this email.\n",
+			inviter.Name, org.Name, invitation.Role, instructions, s.config.InvitationTTL),
+	})
+}
@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"microservices/pkg/mailer"
+	"microservices/pkg/validator"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+	"net/url"
+)
+
+// RequestMagicLink mails a single-use sign-in token. Like ForgotPassword it
+// succeeds silently for unknown addresses, for repeated requests inside the
+// resend interval and when the mail cannot be delivered, so it never reveals
+// whether an account exists.
+func (s *service) RequestMagicLink(ctx context.Context, email string) error {
+	v := validator.New()
+	if validateEmail(v, email); !v.Valid() {
+		return ErrFailedValidation
+	}
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return nil
+		default:
+			return err
+		}
+	}
+
+	throttled, err := s.throttled(ctx, repository.ScopeMagicLink, user.ID, s.config.MailResendInterval)
+	if err != nil || throttled {
+		return err
+	}
+
+	if err := s.sendMagicLink(ctx, user); err != nil {
+		log.Printf("send magic link to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// SignInWithMagicLink exchanges a magic link token for the same result as
+// SignIn, including the MFA challenge for accounts that have it enabled.
+func (s *service) SignInWithMagicLink(ctx context.Context, input MagicLinkSignInDTO) (Tokens, error) {
+	userID, err := s.consumeUserToken(ctx, repository.ScopeMagicLink, input.Token)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	err = s.userTokens.DeleteAllForUser(ctx, repository.ScopeMagicLink, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if err := checkAccountStatus(user); err != nil {
+		return Tokens{}, err
+	}
+
+	// Receiving the token proves the user controls the address.
+	err = s.repo.MarkEmailVerified(ctx, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	mfaEnabled, err := s.mfaEnabled(ctx, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+	if mfaEnabled {
+		return s.issueMFAChallenge(ctx, userID)
+	}
+
+	return s.startSession(ctx, userID, input.UserAgent, input.IP)
+}
+
+func (s *service) sendMagicLink(ctx context.Context, user *domain.User) error {
+	token, err := s.issueUserToken(ctx, user.ID, repository.ScopeMagicLink, s.config.MagicLinkTTL)
+	if err != nil {
+		return err
+	}
+
+	instructions := fmt.Sprintf("send the token below to POST /v1/user/signin/magic/verify:\n\n"+
+		"{\"token\": \"%s\"}", token)
+	if s.config.MagicLinkURL != "" {
+		instructions = fmt.Sprintf("open the link below:\n\n%s?token=%s", s.config.MagicLinkURL, url.QueryEscape(token))
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"To sign in without a password, %s\n\n"+
+			"The link can be used once and expires in %s. If you did not ask to sign in you can ignore this email.\n",
+			user.Name, instructions, s.config.MagicLinkTTL),
+	})
+}
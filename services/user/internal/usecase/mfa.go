@@ -0,0 +1,317 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"microservices/pkg/token"
+	"microservices/pkg/totp"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const recoveryCodeCount = 10
+
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+func (s *service) EnrollTOTP(ctx context.Context, userID int64) (TOTPEnrollment, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	ciphertext, err := s.config.MFASecrets.Seal(secret, totpAssociatedData(userID))
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	err = s.mfa.UpsertTOTP(ctx, userID, ciphertext)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDuplicate):
+			return TOTPEnrollment{}, ErrMFAAlreadyEnabled
+		default:
+			return TOTPEnrollment{}, err
+		}
+	}
+
+	return TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(secret, s.config.MFAIssuer, user.Email),
+	}, nil
+}
+
+// ConfirmTOTP enables MFA once the user proves their authenticator produces
+// valid codes, and returns the recovery codes. They are only shown once.
+func (s *service) ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error) {
+	secret, err := s.mfa.GetTOTP(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return nil, ErrMFANotEnabled
+		default:
+			return nil, err
+		}
+	}
+	if secret.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	plain, err := s.totpSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := totp.Validate(plain, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	err = s.mfa.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			return nil, ErrInvalidMFACode
+		default:
+			return nil, err
+		}
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.mfa.ReplaceRecoveryCodes(ctx, userID, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.mfa.ConfirmTOTP(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			return nil, ErrMFAAlreadyEnabled
+		default:
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+func (s *service) DisableTOTP(ctx context.Context, userID int64, code string) error {
+	err := s.verifySecondFactor(ctx, userID, code)
+	if err != nil {
+		return err
+	}
+	return s.mfa.DeleteTOTP(ctx, userID)
+}
+
+// CompleteMFASignIn exchanges the challenge returned by SignIn and a TOTP or
+// recovery code for a token pair. A challenge can only be tried once.
+func (s *service) CompleteMFASignIn(ctx context.Context, input MFASignInDTO) (Tokens, error) {
+	userID, err := s.consumeUserToken(ctx, repository.ScopeMFAChallenge, input.MFAToken)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	keys := signInKeys(user.Email, input.IP)
+
+	locked, err := s.lockedOut(ctx, keys)
+	if err != nil {
+		return Tokens{}, err
+	}
+	if locked {
+		return Tokens{}, ErrRateLimited
+	}
+
+	err = s.verifySecondFactor(ctx, userID, input.Code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.recordFailedSignIn(ctx, keys, user.Email); err != nil {
+				return Tokens{}, err
+			}
+		}
+		return Tokens{}, err
+	}
+
+	err = s.loginFailures.Reset(ctx, keys.email)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return s.startSession(ctx, userID, input.UserAgent, input.IP)
+}
+
+// EncryptTOTPSecrets encrypts the TOTP secrets stored in plaintext before
+// encryption at rest was introduced, and returns how many it encrypted.
+func (s *service) EncryptTOTPSecrets(ctx context.Context) (int, error) {
+	secrets, err := s.mfa.GetPlaintextTOTPs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, secret := range secrets {
+		ciphertext, err := s.config.MFASecrets.Seal(secret.Secret, totpAssociatedData(secret.UserID))
+		if err != nil {
+			return 0, err
+		}
+
+		err = s.mfa.EncryptTOTP(ctx, secret.UserID, ciphertext)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(secrets), nil
+}
+
+// totpSecret returns the plaintext secret, decrypting it unless the row
+// predates encryption at rest.
+func (s *service) totpSecret(secret *domain.TOTP) (string, error) {
+	if secret.SecretCiphertext == nil {
+		return secret.Secret, nil
+	}
+	return s.config.MFASecrets.Open(secret.SecretCiphertext, totpAssociatedData(secret.UserID))
+}
+
+// totpAssociatedData binds a sealed secret to its owner.
+func totpAssociatedData(userID int64) []byte {
+	return []byte(strconv.FormatInt(userID, 10))
+}
+
+// mfaEnabled reports whether the user has a confirmed TOTP authenticator.
+func (s *service) mfaEnabled(ctx context.Context, userID int64) (bool, error) {
+	secret, err := s.mfa.GetTOTP(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+	return secret.ConfirmedAt != nil, nil
+}
+
+func (s *service) issueMFAChallenge(ctx context.Context, userID int64) (Tokens, error) {
+	challenge, err := s.issueUserToken(ctx, userID, repository.ScopeMFAChallenge, s.config.MFAChallengeTTL)
+	if err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{MFARequired: true, MFAToken: challenge}, nil
+}
+
+func (s *service) verifySecondFactor(ctx context.Context, userID int64, code string) error {
+	secret, err := s.mfa.GetTOTP(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return ErrMFANotEnabled
+		default:
+			return err
+		}
+	}
+	if secret.ConfirmedAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	plain, err := s.totpSecret(secret)
+	if err != nil {
+		return err
+	}
+
+	if step, ok := totp.Validate(plain, code, time.Now()); ok {
+		err = s.mfa.UseTOTPStep(ctx, userID, step)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrEditConflict):
+				return ErrInvalidMFACode
+			default:
+				return err
+			}
+		}
+		return nil
+	}
+
+	err = s.mfa.ConsumeRecoveryCode(ctx, userID, token.HashOpaqueToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return ErrInvalidMFACode
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// withoutMFAOnlyPermissions drops the permissions listed in
+// MFARequiredPermissions from users who have not enabled MFA.
+func (s *service) withoutMFAOnlyPermissions(ctx context.Context, userID int64, permissions []string) ([]string, error) {
+	if len(s.config.MFARequiredPermissions) == 0 {
+		return permissions, nil
+	}
+
+	enabled, err := s.mfaEnabled(ctx, userID)
+	if err != nil || enabled {
+		return permissions, err
+	}
+
+	filtered := []string{}
+	for _, permission := range permissions {
+		if !contains(s.config.MFARequiredPermissions, permission) {
+			filtered = append(filtered, permission)
+		}
+	}
+	return filtered, nil
+}
+
+func newRecoveryCodes() ([]string, [][]byte, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([][]byte, recoveryCodeCount)
+
+	for i := range codes {
+		randomBytes := make([]byte, 7)
+		if _, err := rand.Read(randomBytes); err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(encoding.EncodeToString(randomBytes))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = token.HashOpaqueToken(code)
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"microservices/pkg/oidc"
+	"microservices/pkg/token"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+	"strings"
+	"time"
+)
+
+// OIDCProvider is an OpenID Connect relying party for one provider, such as
+// *oidc.Provider.
+type OIDCProvider interface {
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier string) (string, error)
+	VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*oidc.IDToken, error)
+}
+
+// BeginOIDCLogin returns the authorization URL of the provider. The state,
+// nonce and PKCE verifier are kept server-side until the callback.
+func (s *service) BeginOIDCLogin(ctx context.Context, providerName string) (string, error) {
+	provider, ok := s.config.OIDCProviders[providerName]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	state, stateHash, err := token.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	nonce, _, err := token.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	codeVerifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", err
+	}
+
+	loginState := domain.OIDCLoginState{
+		Hash:         stateHash,
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(s.config.OIDCStateTTL),
+	}
+
+	// Abandoned sign-ins leave their state behind; clear those out as new
+	// ones come in.
+	err = s.oidc.DeleteExpiredLoginStates(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.oidc.InsertLoginState(ctx, &loginState)
+	if err != nil {
+		return "", err
+	}
+
+	return provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+}
+
+// CompleteOIDCLogin handles the redirect back from the provider: it redeems
+// the code, validates the ID token, links the external identity to a user
+// and then continues like SignIn.
+func (s *service) CompleteOIDCLogin(ctx context.Context, input OIDCCallbackDTO) (Tokens, error) {
+	provider, ok := s.config.OIDCProviders[input.Provider]
+	if !ok {
+		return Tokens{}, ErrUnknownProvider
+	}
+
+	loginState, err := s.oidc.ConsumeLoginState(ctx, input.Provider, token.HashOpaqueToken(input.State))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return Tokens{}, ErrInvalidToken
+		default:
+			return Tokens{}, err
+		}
+	}
+
+	if input.Error != "" || input.Code == "" {
+		return Tokens{}, ErrExternalAuthFailed
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, input.Code, loginState.CodeVerifier)
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrExchange):
+			return Tokens{}, ErrExternalAuthFailed
+		default:
+			return Tokens{}, err
+		}
+	}
+
+	idToken, err := provider.VerifyIDToken(ctx, rawIDToken, loginState.Nonce)
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrInvalidIDToken):
+			return Tokens{}, ErrExternalAuthFailed
+		default:
+			return Tokens{}, err
+		}
+	}
+
+	user, err := s.linkExternalIdentity(ctx, input.Provider, idToken)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	// The provider authenticated the user, so a forced password reset does
+	// not apply here; a disabled account does.
+	if user.DisabledAt != nil {
+		return Tokens{}, ErrAccountDisabled
+	}
+
+	mfaEnabled, err := s.mfaEnabled(ctx, user.ID)
+	if err != nil {
+		return Tokens{}, err
+	}
+	if mfaEnabled {
+		return s.issueMFAChallenge(ctx, user.ID)
+	}
+
+	return s.startSession(ctx, user.ID, input.UserAgent, input.IP)
+}
+
+// linkExternalIdentity finds the user an external identity belongs to. An
+// identity seen for the first time is linked to the account with the same
+// email address, or to a new account, but only if the provider vouches for
+// the address; otherwise anyone could claim an existing account.
+func (s *service) linkExternalIdentity(ctx context.Context, provider string, idToken *oidc.IDToken) (*domain.User, error) {
+	identity, err := s.oidc.GetIdentity(ctx, provider, idToken.Subject)
+	if err == nil {
+		return s.getUser(ctx, identity.UserID)
+	}
+	if !errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if idToken.Email == "" || !idToken.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	user, err := s.repo.GetByEmail(ctx, idToken.Email)
+	if err == nil {
+		err = s.insertIdentity(ctx, provider, idToken, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if !errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if s.config.SignUpDisabled {
+		return nil, ErrSignUpDisabled
+	}
+
+	// The account and its link to the identity are created together, so a
+	// failure cannot leave an account that the next sign-in does not find.
+	err = s.inTx(ctx, func(tx *service) error {
+		user, err = tx.createExternalUser(ctx, idToken)
+		if err != nil {
+			return err
+		}
+		return tx.insertIdentity(ctx, provider, idToken, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *service) insertIdentity(ctx context.Context, provider string, idToken *oidc.IDToken, userID int64) error {
+	err := s.oidc.InsertIdentity(ctx, &domain.ExternalIdentity{
+		Provider: provider,
+		Subject:  idToken.Subject,
+		UserID:   userID,
+		Email:    idToken.Email,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDuplicate):
+			return ErrDuplicate
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// createExternalUser signs up a user without a password.
+func (s *service) createExternalUser(ctx context.Context, idToken *oidc.IDToken) (*domain.User, error) {
+	name := idToken.Name
+	if name == "" {
+		name, _, _ = strings.Cut(idToken.Email, "@")
+	}
+
+	user := domain.User{
+		Name:  truncate(name, 100),
+		Email: idToken.Email,
+	}
+
+	err := s.repo.Insert(ctx, &user)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDuplicate):
+			return nil, ErrDuplicate
+		default:
+			return nil, err
+		}
+	}
+
+	err = s.roles.AddForUser(ctx, user.ID, DefaultRole)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.createPersonalOrganization(ctx, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.MarkEmailVerified(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
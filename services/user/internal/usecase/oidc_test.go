@@ -0,0 +1,374 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"microservices/pkg/oidc"
+	"microservices/pkg/oidc/oidctest"
+	"microservices/pkg/token"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testProvider = "corporate"
+	testClientID = "test-client"
+)
+
+// The fakes implement what CompleteOIDCLogin needs for an identity that is
+// already linked; any other method panics through the nil embedded
+// interface.
+
+type fakeOIDCRepo struct {
+	repository.OIDC
+
+	mu         sync.Mutex
+	states     map[string]domain.OIDCLoginState
+	identities map[string]domain.ExternalIdentity
+}
+
+func (r *fakeOIDCRepo) InsertLoginState(ctx context.Context, state *domain.OIDCLoginState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[string(state.Hash)] = *state
+	return nil
+}
+
+func (r *fakeOIDCRepo) ConsumeLoginState(ctx context.Context, provider string, hash []byte) (*domain.OIDCLoginState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, ok := r.states[string(hash)]
+	if !ok || state.Provider != provider || time.Now().After(state.ExpiresAt) {
+		return nil, repository.ErrRecordNotFound
+	}
+	delete(r.states, string(hash))
+	return &state, nil
+}
+
+func (r *fakeOIDCRepo) DeleteExpiredLoginStates(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for hash, state := range r.states {
+		if !time.Now().Before(state.ExpiresAt) {
+			delete(r.states, hash)
+		}
+	}
+	return nil
+}
+
+func (r *fakeOIDCRepo) GetIdentity(ctx context.Context, provider, subject string) (*domain.ExternalIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	identity, ok := r.identities[provider+"/"+subject]
+	if !ok {
+		return nil, repository.ErrRecordNotFound
+	}
+	return &identity, nil
+}
+
+// tamper changes every stored login state, standing in for a callback that
+// does not belong to the sign-in that was started.
+func (r *fakeOIDCRepo) tamper(fn func(state *domain.OIDCLoginState)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for hash, state := range r.states {
+		fn(&state)
+		r.states[hash] = state
+	}
+}
+
+type fakeUserRepo struct {
+	repository.User
+	users map[int64]domain.User
+}
+
+func (r *fakeUserRepo) GetByID(ctx context.Context, id int64) (*domain.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, repository.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+func (r *fakeUserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, repository.ErrRecordNotFound
+}
+
+type fakeRoleRepo struct {
+	repository.Role
+}
+
+func (r *fakeRoleRepo) GetAllForUser(ctx context.Context, userID int64) ([]string, error) {
+	return []string{DefaultRole}, nil
+}
+
+func (r *fakeRoleRepo) GetPermissionsForUser(ctx context.Context, userID int64) ([]string, error) {
+	return []string{"contracts:read"}, nil
+}
+
+type fakeMFARepo struct {
+	repository.MFA
+}
+
+func (r *fakeMFARepo) GetTOTP(ctx context.Context, userID int64) (*domain.TOTP, error) {
+	return nil, repository.ErrRecordNotFound
+}
+
+type fakeOrganizationRepo struct {
+	repository.Organization
+}
+
+func (r *fakeOrganizationRepo) GetDefaultMembership(ctx context.Context, userID int64) (*domain.OrganizationMember, error) {
+	return nil, repository.ErrRecordNotFound
+}
+
+type fakeSessionRepo struct {
+	repository.Session
+	sessions []domain.Session
+}
+
+func (r *fakeSessionRepo) Insert(ctx context.Context, session *domain.Session) error {
+	session.ID = int64(len(r.sessions) + 1)
+	r.sessions = append(r.sessions, *session)
+	return nil
+}
+
+type fakeRefreshTokenRepo struct {
+	repository.RefreshToken
+}
+
+func (r *fakeRefreshTokenRepo) Insert(ctx context.Context, token *domain.RefreshToken) error {
+	return nil
+}
+
+type oidcTestEnv struct {
+	service  *service
+	server   *oidctest.Server
+	oidc     *fakeOIDCRepo
+	sessions *fakeSessionRepo
+	tokens   *token.Manager
+}
+
+func newOIDCTestEnv(t *testing.T) *oidcTestEnv {
+	t.Helper()
+
+	server, err := oidctest.NewServer(testClientID, oidctest.User{
+		Subject:       "subject-1",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Name:          "Alice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	tokens, err := token.NewManager("test-secret", "test-issuer", "test-audience")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oidcRepo := &fakeOIDCRepo{
+		states: make(map[string]domain.OIDCLoginState),
+		identities: map[string]domain.ExternalIdentity{
+			testProvider + "/subject-1": {Provider: testProvider, Subject: "subject-1", UserID: 1, Email: "alice@example.com"},
+		},
+	}
+	sessions := &fakeSessionRepo{}
+
+	repos := repository.Repositories{
+		Users:         &fakeUserRepo{users: map[int64]domain.User{1: {ID: 1, Name: "Alice", Email: "alice@example.com"}}},
+		RefreshTokens: &fakeRefreshTokenRepo{},
+		Roles:         &fakeRoleRepo{},
+		MFA:           &fakeMFARepo{},
+		Sessions:      sessions,
+		OIDC:          oidcRepo,
+		Organizations: &fakeOrganizationRepo{},
+	}
+
+	cfg := Config{
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 24 * time.Hour,
+		OIDCStateTTL:    10 * time.Minute,
+		OIDCProviders: map[string]OIDCProvider{
+			testProvider: oidc.NewProvider(oidc.Config{
+				Issuer:      server.Issuer(),
+				ClientID:    testClientID,
+				RedirectURL: "http://localhost/callback",
+			}),
+		},
+	}
+
+	return &oidcTestEnv{
+		service:  New(repos, nil, tokens, nil, cfg),
+		server:   server,
+		oidc:     oidcRepo,
+		sessions: sessions,
+		tokens:   tokens,
+	}
+}
+
+// begin starts a sign-in and lets the test provider approve it, returning
+// the callback the browser would be redirected to.
+func (e *oidcTestEnv) begin(t *testing.T) OIDCCallbackDTO {
+	t.Helper()
+
+	authURL, err := e.service.BeginOIDCLogin(context.Background(), testProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return OIDCCallbackDTO{
+		Provider: testProvider,
+		Code:     location.Query().Get("code"),
+		State:    location.Query().Get("state"),
+	}
+}
+
+func TestCompleteOIDCLogin(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, env *oidcTestEnv, callback *OIDCCallbackDTO)
+		wantErr error
+	}{
+		{
+			name: "linked identity",
+		},
+		{
+			name: "state mismatch",
+			prepare: func(t *testing.T, env *oidcTestEnv, callback *OIDCCallbackDTO) {
+				callback.State = "not-the-issued-state"
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "state used twice",
+			prepare: func(t *testing.T, env *oidcTestEnv, callback *OIDCCallbackDTO) {
+				_, err := env.service.CompleteOIDCLogin(context.Background(), *callback)
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "state of another provider",
+			prepare: func(t *testing.T, env *oidcTestEnv, callback *OIDCCallbackDTO) {
+				env.oidc.tamper(func(state *domain.OIDCLoginState) { state.Provider = "other" })
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "expired state",
+			prepare: func(t *testing.T, env *oidcTestEnv, callback *OIDCCallbackDTO) {
+				env.oidc.tamper(func(state *domain.OIDCLoginState) { state.ExpiresAt = time.Now().Add(-time.Second) })
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "nonce mismatch",
+			prepare: func(t *testing.T, env *oidcTestEnv, callback *OIDCCallbackDTO) {
+				env.oidc.tamper(func(state *domain.OIDCLoginState) { state.Nonce = "another-nonce" })
+			},
+			wantErr: ErrExternalAuthFailed,
+		},
+		{
+			name: "code verifier mismatch",
+			prepare: func(t *testing.T, env *oidcTestEnv, callback *OIDCCallbackDTO) {
+				env.oidc.tamper(func(state *domain.OIDCLoginState) { state.CodeVerifier = "another-verifier" })
+			},
+			wantErr: ErrExternalAuthFailed,
+		},
+		{
+			name: "provider error",
+			prepare: func(t *testing.T, env *oidcTestEnv, callback *OIDCCallbackDTO) {
+				callback.Code = ""
+				callback.Error = "access_denied"
+			},
+			wantErr: ErrExternalAuthFailed,
+		},
+		{
+			name: "unverified email for a new identity",
+			prepare: func(t *testing.T, env *oidcTestEnv, callback *OIDCCallbackDTO) {
+				env.server.SetUser(oidctest.User{Subject: "subject-2", Email: "bob@example.com"})
+				*callback = env.begin(t)
+			},
+			wantErr: ErrEmailNotVerified,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOIDCTestEnv(t)
+			callback := env.begin(t)
+
+			if tt.prepare != nil {
+				tt.prepare(t, env, &callback)
+			}
+
+			tokens, err := env.service.CompleteOIDCLogin(context.Background(), callback)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := env.tokens.Parse(tokens.AccessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserID != 1 {
+				t.Errorf("got access token for user %d, want 1", claims.UserID)
+			}
+			if len(env.sessions.sessions) != 1 {
+				t.Errorf("got %d sessions, want 1", len(env.sessions.sessions))
+			}
+		})
+	}
+}
+
+func TestBeginOIDCLoginUnknownProvider(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	_, err := env.service.BeginOIDCLogin(context.Background(), "unknown")
+	if !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("got error %v, want %v", err, ErrUnknownProvider)
+	}
+}
@@ -0,0 +1,225 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"microservices/pkg/validator"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+)
+
+// Roles a user can hold within an organization. Owners can do anything,
+// admins manage members other than owners, members only see the roster.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+func (s *service) CreateOrganization(ctx context.Context, userID int64, input CreateOrganizationDTO) (*domain.Organization, error) {
+	v := validator.New()
+	if validateOrganizationName(v, input.Name); !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	org := domain.Organization{Name: input.Name}
+
+	err := s.orgs.Insert(ctx, &org, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (s *service) ListOrganizations(ctx context.Context, userID int64) ([]*domain.Organization, error) {
+	return s.orgs.GetAllForUser(ctx, userID)
+}
+
+// GetOrganization returns the organization if the user is a member of it.
+func (s *service) GetOrganization(ctx context.Context, userID, orgID int64) (*domain.Organization, error) {
+	member, err := s.membership(ctx, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.orgs.GetByID(ctx, orgID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	org.Role = member.Role
+	return org, nil
+}
+
+func (s *service) ListMembers(ctx context.Context, userID, orgID int64) ([]*domain.OrganizationMember, error) {
+	_, err := s.membership(ctx, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.orgs.GetMembers(ctx, orgID)
+}
+
+func (s *service) UpdateMemberRole(ctx context.Context, actorID, orgID, userID int64, role string) error {
+	v := validator.New()
+	if validateOrgRole(v, role); !v.Valid() {
+		return ErrFailedValidation
+	}
+
+	target, err := s.membership(ctx, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkCanManage(ctx, actorID, orgID, target.Role, role)
+	if err != nil {
+		return err
+	}
+
+	if target.Role == OrgRoleOwner && role != OrgRoleOwner {
+		if err := s.checkNotLastOwner(ctx, orgID); err != nil {
+			return err
+		}
+	}
+
+	return s.orgs.UpdateMemberRole(ctx, orgID, userID, role)
+}
+
+// RemoveMember takes a user out of the organization. Members may always
+// leave on their own, except for the last owner.
+func (s *service) RemoveMember(ctx context.Context, actorID, orgID, userID int64) error {
+	target, err := s.membership(ctx, orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	if actorID != userID {
+		err = s.checkCanManage(ctx, actorID, orgID, target.Role, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	if target.Role == OrgRoleOwner {
+		if err := s.checkNotLastOwner(ctx, orgID); err != nil {
+			return err
+		}
+	}
+
+	err = s.orgs.RemoveMember(ctx, orgID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// SwitchOrganization binds the session to another organization the user
+// belongs to and returns an access token for it. Later refreshes keep the
+// new organization.
+func (s *service) SwitchOrganization(ctx context.Context, userID, sessionID, orgID int64) (Tokens, error) {
+	if sessionID == 0 {
+		return Tokens{}, ErrFailedValidation
+	}
+
+	_, err := s.membership(ctx, orgID, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	session, err := s.sessions.GetByID(ctx, sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return Tokens{}, ErrInvalidToken
+		default:
+			return Tokens{}, err
+		}
+	}
+	if session.UserID != userID || session.RevokedAt != nil {
+		return Tokens{}, ErrInvalidToken
+	}
+
+	err = s.sessions.SetOrganization(ctx, sessionID, &orgID)
+	if err != nil {
+		return Tokens{}, err
+	}
+	session.OrgID = &orgID
+
+	accessToken, err := s.accessToken(ctx, session)
+	if err != nil {
+		return Tokens{}, err
+	}
+	return Tokens{AccessToken: accessToken}, nil
+}
+
+// createPersonalOrganization gives a new user an organization of their own
+// to work in.
+func (s *service) createPersonalOrganization(ctx context.Context, user *domain.User) error {
+	org := domain.Organization{Name: truncate(user.Name+"'s organization", 100)}
+	return s.orgs.Insert(ctx, &org, user.ID)
+}
+
+// membership returns the user's membership of the organization. Users
+// outside it get ErrRecordNotFound, so they cannot tell it exists.
+func (s *service) membership(ctx context.Context, orgID, userID int64) (*domain.OrganizationMember, error) {
+	member, err := s.orgs.GetMember(ctx, orgID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return member, nil
+}
+
+// checkCanManage reports whether the actor may change a membership from
+// currentRole to newRole; an empty role stands for no membership. Admins
+// manage admins and members, anything involving owners needs an owner.
+func (s *service) checkCanManage(ctx context.Context, actorID, orgID int64, currentRole, newRole string) error {
+	actor, err := s.membership(ctx, orgID, actorID)
+	if err != nil {
+		return err
+	}
+
+	switch actor.Role {
+	case OrgRoleOwner:
+		return nil
+	case OrgRoleAdmin:
+		if currentRole == OrgRoleOwner || newRole == OrgRoleOwner {
+			return ErrNotPermitted
+		}
+		return nil
+	default:
+		return ErrNotPermitted
+	}
+}
+
+func (s *service) checkNotLastOwner(ctx context.Context, orgID int64) error {
+	owners, err := s.orgs.CountOwners(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func validateOrganizationName(v *validator.Validator, name string) {
+	v.Check(name != "", "name", "must be provided")
+	v.Check(len(name) <= 100, "name", "must not be more than 100 bytes long")
+}
+
+func validateOrgRole(v *validator.Validator, role string) {
+	v.Check(validator.In(role, OrgRoleOwner, OrgRoleAdmin, OrgRoleMember), "role", "must be owner, admin or member")
+}
@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"microservices/pkg/mailer"
+	"microservices/pkg/validator"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+)
+
+// ForgotPassword mails a reset token. Unknown addresses, repeated requests
+// inside the resend interval and failed deliveries succeed silently so the
+// response never reveals whether an account exists.
+func (s *service) ForgotPassword(ctx context.Context, email string) error {
+	v := validator.New()
+	if validateEmail(v, email); !v.Valid() {
+		return ErrFailedValidation
+	}
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return nil
+		default:
+			return err
+		}
+	}
+
+	throttled, err := s.throttled(ctx, repository.ScopePasswordReset, user.ID, s.config.MailResendInterval)
+	if err != nil || throttled {
+		return err
+	}
+
+	err = s.sendPasswordReset(ctx, user,
+		"Someone asked to reset the password of your account. If it was you, send the token below "+
+			"together with your new password to POST /v1/user/password/reset:",
+		"If you did not ask for a reset you can ignore this email.")
+	if err != nil {
+		log.Printf("send password reset email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// sendPasswordReset issues a reset token and mails it between intro and
+// outro.
+func (s *service) sendPasswordReset(ctx context.Context, user *domain.User, intro, outro string) error {
+	token, err := s.issueUserToken(ctx, user.ID, repository.ScopePasswordReset, s.config.PasswordResetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"%s\n\n"+
+			"{\"token\": \"%s\", \"password\": \"...\"}\n\n"+
+			"The token expires in %s. %s\n",
+			user.Name, intro, token, s.config.PasswordResetTokenTTL, outro),
+	})
+}
+
+func (s *service) ResetPassword(ctx context.Context, input ResetPasswordDTO) error {
+	v := validator.New()
+	if validatePassword(v, input.Password); !v.Valid() {
+		return ErrFailedValidation
+	}
+
+	// The policy is checked before the token is spent so that a rejected
+	// password can be retried with the same link.
+	userID, err := s.lookupUserToken(ctx, repository.ScopePasswordReset, input.Token)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkPasswordPolicy(input.Password, user.Name, user.Email)
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := s.hasher.Hash(input.Password)
+	if err != nil {
+		return err
+	}
+
+	// The token is only spent together with the rest of the reset, so a
+	// failure leaves the link usable and the old sessions never outlive the
+	// old password.
+	return s.inTx(ctx, func(tx *service) error {
+		userID, err := tx.consumeUserToken(ctx, repository.ScopePasswordReset, input.Token)
+		if err != nil {
+			return err
+		}
+
+		err = tx.repo.UpdatePasswordHash(ctx, userID, passwordHash)
+		if err != nil {
+			return err
+		}
+
+		err = tx.userTokens.DeleteAllForUser(ctx, repository.ScopePasswordReset, userID)
+		if err != nil {
+			return err
+		}
+
+		// Receiving the token proves the user controls the address.
+		err = tx.repo.MarkEmailVerified(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		// The lockout protected the old password; the owner has just proven
+		// themselves, so they can sign in with the new one straight away.
+		err = tx.loginFailures.Reset(ctx, signInKeys(user.Email, "").email)
+		if err != nil {
+			return err
+		}
+
+		return tx.revokeAllSessions(ctx, userID)
+	})
+}
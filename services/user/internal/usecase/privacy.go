@@ -0,0 +1,369 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"microservices/pkg/privacy"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+	"sort"
+	"time"
+)
+
+const (
+	// accountStep names the part of a privacy request handled by this
+	// service; other steps are named after Config.PrivacyServices.
+	accountStep = "account"
+
+	maxPrivacyAttempts = 5
+	privacyStaleAfter  = 10 * time.Minute
+)
+
+// AuditUserErased is recorded once an erasure request has completed.
+const AuditUserErased = "user.erased"
+
+// PrivacyService is another service holding personal data, reached through
+// its /v1/privacy endpoints.
+type PrivacyService interface {
+	Export(ctx context.Context, actorID int64, subject privacy.Subject) (json.RawMessage, error)
+	Erase(ctx context.Context, actorID int64, subject privacy.Subject) (int64, error)
+}
+
+// privacyStepError hides the details of a failed step from the status
+// resource; they are logged instead.
+type privacyStepError struct {
+	step string
+	err  error
+}
+
+func (e privacyStepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.step, e.err)
+}
+
+func (e privacyStepError) Unwrap() error {
+	return e.err
+}
+
+// RequestDataExport queues an export of everything held about the user.
+// If one is already open it is returned instead.
+func (s *service) RequestDataExport(ctx context.Context, actorID, userID int64) (*domain.PrivacyRequest, error) {
+	return s.requestPrivacy(ctx, actorID, userID, repository.PrivacyExport)
+}
+
+// RequestErasure queues the erasure of the user across all services.
+func (s *service) RequestErasure(ctx context.Context, actorID, userID int64) (*domain.PrivacyRequest, error) {
+	return s.requestPrivacy(ctx, actorID, userID, repository.PrivacyErasure)
+}
+
+// RequestOwnErasure is RequestErasure for a signed-in user, who has to
+// confirm with their password.
+func (s *service) RequestOwnErasure(ctx context.Context, userID int64, password string) (*domain.PrivacyRequest, error) {
+	_, err := s.checkPassword(ctx, userID, password)
+	if err != nil {
+		return nil, err
+	}
+	return s.requestPrivacy(ctx, userID, userID, repository.PrivacyErasure)
+}
+
+func (s *service) GetPrivacyRequest(ctx context.Context, id int64) (*domain.PrivacyRequest, error) {
+	request, err := s.privacy.GetByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+// GetPrivacyArchive returns the archive of a completed export, for
+// PrivacyArchiveTTL after it was made.
+func (s *service) GetPrivacyArchive(ctx context.Context, id int64) ([]byte, error) {
+	archive, err := s.privacy.GetArchive(ctx, id, time.Now().Add(-s.config.PrivacyArchiveTTL))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return archive, nil
+}
+
+// ProcessPrivacyRequests works through queued privacy requests until ctx is
+// cancelled, checking for new ones every PrivacyPollInterval. Failed
+// requests are retried on later polls, up to maxPrivacyAttempts times.
+// Export archives are deleted once they are older than PrivacyArchiveTTL.
+//
+// A request already being processed when ctx is cancelled is finished
+// first rather than left to go stale, so callers should wait for
+// ProcessPrivacyRequests to return before exiting.
+func (s *service) ProcessPrivacyRequests(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PrivacyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil && s.processNextPrivacyRequest(context.Background()) {
+		}
+
+		s.expirePrivacyArchives(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *service) expirePrivacyArchives(ctx context.Context) {
+	deleted, err := s.privacy.DeleteArchivesCompletedBefore(ctx, time.Now().Add(-s.config.PrivacyArchiveTTL))
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("delete expired privacy archives: %v", err)
+		}
+		return
+	}
+	if deleted > 0 {
+		log.Printf("deleted %d expired privacy archives", deleted)
+	}
+}
+
+// processNextPrivacyRequest reports whether a request was claimed and
+// processed successfully.
+func (s *service) processNextPrivacyRequest(ctx context.Context) bool {
+	request, err := s.privacy.ClaimNext(ctx, privacyStaleAfter)
+	if err != nil {
+		if !errors.Is(err, repository.ErrRecordNotFound) {
+			log.Printf("claim privacy request: %v", err)
+		}
+		return false
+	}
+
+	if request.Steps == nil {
+		request.Steps = map[string]string{}
+	}
+
+	switch request.Kind {
+	case repository.PrivacyExport:
+		err = s.runExport(ctx, request)
+	case repository.PrivacyErasure:
+		err = s.runErasure(ctx, request)
+	default:
+		err = fmt.Errorf("unknown kind %q", request.Kind)
+	}
+	if err == nil {
+		return true
+	}
+
+	log.Printf("privacy request %d: %v", request.ID, err)
+
+	request.Status = repository.PrivacyPending
+	if request.Attempts >= maxPrivacyAttempts {
+		request.Status = repository.PrivacyFailed
+	}
+
+	request.Error = "processing failed"
+	var stepErr privacyStepError
+	if errors.As(err, &stepErr) {
+		request.Error = stepErr.step + " could not be processed"
+	}
+
+	if err := s.privacy.UpdateProgress(ctx, request); err != nil {
+		log.Printf("update privacy request %d: %v", request.ID, err)
+	}
+	return false
+}
+
+func (s *service) runExport(ctx context.Context, request *domain.PrivacyRequest) error {
+	user, err := s.repo.GetByID(ctx, request.UserID)
+	if err != nil {
+		return privacyStepError{accountStep, err}
+	}
+
+	account, err := s.exportAccount(ctx, user)
+	if err != nil {
+		return privacyStepError{accountStep, err}
+	}
+
+	err = s.completeStep(ctx, request, accountStep)
+	if err != nil {
+		return err
+	}
+
+	subject := privacy.Subject{UserID: user.ID, Email: user.Email}
+	services := map[string]json.RawMessage{}
+
+	for _, name := range s.privacyServiceNames() {
+		data, err := s.config.PrivacyServices[name].Export(ctx, request.RequestedBy, subject)
+		if err != nil {
+			return privacyStepError{name, err}
+		}
+		services[name] = data
+
+		err = s.completeStep(ctx, request, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	archive, err := json.Marshal(map[string]any{
+		"generatedAt": time.Now().UTC(),
+		"account":     account,
+		"services":    services,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.privacy.Complete(ctx, request, archive)
+}
+
+// runErasure scrubs the other services first, as they are keyed by the
+// user's email address, and anonymises the account last. Steps that have
+// completed are skipped when a failed request is retried.
+func (s *service) runErasure(ctx context.Context, request *domain.PrivacyRequest) error {
+	user, err := s.repo.GetByID(ctx, request.UserID)
+	if err != nil {
+		return privacyStepError{accountStep, err}
+	}
+
+	subject := privacy.Subject{UserID: user.ID, Email: user.Email}
+
+	for _, name := range s.privacyServiceNames() {
+		if request.Steps[name] == repository.PrivacyCompleted {
+			continue
+		}
+
+		_, err := s.config.PrivacyServices[name].Erase(ctx, request.RequestedBy, subject)
+		if err != nil {
+			return privacyStepError{name, err}
+		}
+
+		err = s.completeStep(ctx, request, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.repo.Anonymise(ctx, user.ID)
+	if err != nil {
+		return privacyStepError{accountStep, err}
+	}
+
+	err = s.privacy.DeleteArchivesForUser(ctx, user.ID)
+	if err != nil {
+		return privacyStepError{accountStep, err}
+	}
+
+	err = s.recordAudit(ctx, request.RequestedBy, user.ID, AuditUserErased, map[string]any{"request_id": request.ID})
+	if err != nil {
+		return err
+	}
+
+	request.Steps[accountStep] = repository.PrivacyCompleted
+	return s.privacy.Complete(ctx, request, nil)
+}
+
+// exportAccount gathers what this service holds about the user.
+func (s *service) exportAccount(ctx context.Context, user *domain.User) (map[string]any, error) {
+	roles, err := s.roles.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	mfaEnabled, err := s.mfaEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.sessions.GetActiveForUser(ctx, user.ID, time.Now().Add(-s.config.RefreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys, err := s.apiKeys.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs, err := s.orgs.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	identities, err := s.oidc.GetIdentitiesForUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	externalIdentities := []map[string]any{}
+	for _, identity := range identities {
+		externalIdentities = append(externalIdentities, map[string]any{
+			"provider":  identity.Provider,
+			"subject":   identity.Subject,
+			"email":     identity.Email,
+			"createdAt": identity.CreatedAt,
+		})
+	}
+
+	return map[string]any{
+		"user":               user,
+		"roles":              roles,
+		"mfaEnabled":         mfaEnabled,
+		"organizations":      orgs,
+		"sessions":           sessions,
+		"apiKeys":            apiKeys,
+		"externalIdentities": externalIdentities,
+	}, nil
+}
+
+func (s *service) requestPrivacy(ctx context.Context, actorID, userID int64, kind string) (*domain.PrivacyRequest, error) {
+	_, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := map[string]string{accountStep: repository.PrivacyPending}
+	for _, name := range s.privacyServiceNames() {
+		steps[name] = repository.PrivacyPending
+	}
+
+	request := domain.PrivacyRequest{
+		UserID:      userID,
+		RequestedBy: actorID,
+		Kind:        kind,
+		Steps:       steps,
+	}
+
+	err = s.privacy.Insert(ctx, &request)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDuplicate):
+			return s.privacy.GetOpen(ctx, userID, kind)
+		default:
+			return nil, err
+		}
+	}
+	return &request, nil
+}
+
+func (s *service) completeStep(ctx context.Context, request *domain.PrivacyRequest, step string) error {
+	request.Steps[step] = repository.PrivacyCompleted
+	return s.privacy.UpdateProgress(ctx, request)
+}
+
+func (s *service) privacyServiceNames() []string {
+	names := make([]string, 0, len(s.config.PrivacyServices))
+	for name := range s.config.PrivacyServices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"microservices/pkg/validator"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+)
+
+func (s *service) GetProfile(ctx context.Context, userID int64) (*domain.User, error) {
+	return s.getUser(ctx, userID)
+}
+
+func (s *service) UpdateProfile(ctx context.Context, userID int64, input UpdateProfileDTO) (*domain.User, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Version != nil && *input.Version != user.Version {
+		return nil, ErrEditConflict
+	}
+
+	if input.Name != nil {
+		user.Name = *input.Name
+	}
+
+	v := validator.New()
+	if validateName(v, user.Name); !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	err = s.updateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ChangeEmail moves the account to a new address, which has to be verified
+// again before RequireVerifiedEmail lets the user sign in.
+func (s *service) ChangeEmail(ctx context.Context, userID int64, input ChangeEmailDTO) error {
+	user, err := s.checkPassword(ctx, userID, input.Password)
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+	if validateEmail(v, input.Email); !v.Valid() {
+		return ErrFailedValidation
+	}
+
+	if input.Email == user.Email {
+		return nil
+	}
+
+	user.Email = input.Email
+	user.EmailVerifiedAt = nil
+
+	err = s.updateUser(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	err = s.userTokens.DeleteAllForUser(ctx, repository.ScopeEmailVerification, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.sendEmailVerification(ctx, user); err != nil {
+		log.Printf("send verification email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+func (s *service) ChangePassword(ctx context.Context, userID int64, input ChangePasswordDTO) error {
+	user, err := s.checkPassword(ctx, userID, input.CurrentPassword)
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+	if validatePassword(v, input.NewPassword); !v.Valid() {
+		return ErrFailedValidation
+	}
+
+	err = s.checkPasswordPolicy(input.NewPassword, user.Name, user.Email)
+	if err != nil {
+		return err
+	}
+
+	user.HashPassword, err = s.hasher.Hash(input.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.updateUser(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	return s.revokeAllSessions(ctx, user.ID)
+}
+
+func (s *service) DeleteAccount(ctx context.Context, userID int64, password string) error {
+	_, err := s.checkPassword(ctx, userID, password)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.Delete(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPassword re-authenticates a signed-in user before a sensitive change.
+func (s *service) checkPassword(ctx context.Context, userID int64, password string) (*domain.User, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.HashPassword == "" {
+		return nil, ErrWrongCredentials
+	}
+
+	match, err := s.hasher.Verify(password, user.HashPassword)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, ErrWrongCredentials
+	}
+	return user, nil
+}
+
+func (s *service) updateUser(ctx context.Context, user *domain.User) error {
+	err := s.repo.Update(ctx, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			return ErrEditConflict
+		case errors.Is(err, repository.ErrDuplicate):
+			return ErrDuplicate
+		default:
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+)
+
+// DefaultRole is assigned to every account on sign-up.
+const DefaultRole = "user"
+
+// AdminRole holds every permission.
+const AdminRole = "admin"
+
+func (s *service) ListRoles(ctx context.Context) ([]*domain.Role, error) {
+	return s.roles.GetAll(ctx)
+}
+
+func (s *service) GetUserRoles(ctx context.Context, userID int64) ([]string, error) {
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.roles.GetAllForUser(ctx, userID)
+}
+
+func (s *service) AssignRole(ctx context.Context, actorID, userID int64, role string) error {
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return err
+	}
+
+	err := s.roles.AddForUser(ctx, userID, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return s.recordAudit(ctx, actorID, userID, AuditRoleAssigned, map[string]any{"role": role})
+}
+
+func (s *service) RevokeRole(ctx context.Context, actorID, userID int64, role string) error {
+	err := s.roles.RemoveForUser(ctx, userID, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return s.recordAudit(ctx, actorID, userID, AuditRoleRevoked, map[string]any{"role": role})
+}
+
+// BootstrapAdmin gives the admin role to the account with the email
+// address, so that a new installation has someone to assign roles. The
+// account must exist and have verified its address; granting the role again
+// changes nothing.
+func (s *service) BootstrapAdmin(ctx context.Context, email string) error {
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return fmt.Errorf("bootstrap admin: no account for %s; sign up first", email)
+		default:
+			return err
+		}
+	}
+
+	if user.EmailVerifiedAt == nil {
+		return fmt.Errorf("bootstrap admin: %s has not verified its email address", email)
+	}
+
+	roles, err := s.roles.GetAllForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if role == AdminRole {
+			return nil
+		}
+	}
+
+	return s.inTx(ctx, func(tx *service) error {
+		err := tx.roles.AddForUser(ctx, user.ID, AdminRole)
+		if err != nil {
+			return err
+		}
+		return tx.recordAudit(ctx, user.ID, user.ID, AuditRoleAssigned, map[string]any{"role": AdminRole, "bootstrap": true})
+	})
+}
+
+func (s *service) getUser(ctx context.Context, userID int64) (*domain.User, error) {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return user, nil
+}
@@ -3,12 +3,15 @@ package usecase
 import (
 	"context"
 	"errors"
-	"golang.org/x/crypto/bcrypt"
-	"microservices-go/pkg/hash"
-	"microservices-go/pkg/token"
-	"microservices-go/pkg/validator"
-	"microservices-go/services/user/internal/domain"
-	"microservices-go/services/user/internal/repository"
+	"log"
+	"microservices/pkg/hash"
+	"microservices/pkg/mailer"
+	"microservices/pkg/token"
+	"microservices/pkg/totp"
+	"microservices/pkg/validator"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+	"sync"
 	"time"
 )
 
@@ -16,8 +19,40 @@ var (
 	ErrFailedValidation = errors.New("validation failed")
 	ErrWrongCredentials = errors.New("wrong user credentials")
 	ErrDuplicate        = errors.New("record duplication")
+	ErrRecordNotFound   = errors.New("record not found")
+	ErrEditConflict     = errors.New("edit conflict")
+
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrInvalidToken        = errors.New("invalid or expired token")
+	ErrEmailNotVerified    = errors.New("email address is not verified")
+	ErrRateLimited         = errors.New("rate limit exceeded")
+
+	ErrAccountDisabled       = errors.New("user account is disabled")
+	ErrPasswordResetRequired = errors.New("password reset required")
+
+	ErrUnknownProvider    = errors.New("unknown identity provider")
+	ErrExternalAuthFailed = errors.New("sign-in with the identity provider failed")
+
+	ErrSignUpDisabled = errors.New("sign-up is by invitation only")
+
+	ErrNotPermitted = errors.New("not permitted")
+	ErrLastOwner    = errors.New("an organization needs at least one owner")
+
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidMFACode    = errors.New("invalid two-factor authentication code")
 )
 
+// PasswordRejectedError is returned when a new password fails the password
+// policy; Reason can be shown to the user.
+type PasswordRejectedError struct {
+	Reason string
+}
+
+func (e PasswordRejectedError) Error() string {
+	return "password rejected: " + e.Reason
+}
+
 type UserSignUpDTO struct {
 	Name         string `json:"name"`
 	Email        string `json:"email"`
@@ -27,86 +62,498 @@ type UserSignUpDTO struct {
 type UserSignInDTO struct {
 	Email        string `json:"email"`
 	HashPassword string `json:"hashPassword"`
+	IP           string `json:"-"`
+	UserAgent    string `json:"-"`
 }
 
-type Token struct {
-	PlainText string
+type RefreshTokenDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type VerifyEmailDTO struct {
+	Token string `json:"token"`
+}
+
+type EmailDTO struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordDTO struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type UpdateProfileDTO struct {
+	Name    *string `json:"name"`
+	Version *int    `json:"version"`
+}
+
+type ChangeEmailDTO struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type ChangePasswordDTO struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type PasswordDTO struct {
+	Password string `json:"password"`
+}
+
+type AssignRoleDTO struct {
+	Role string `json:"role"`
+}
+
+type CreateAPIKeyDTO struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	// OrgID is the organization the key acts in, taken from the token of
+	// the user creating it.
+	OrgID int64 `json:"-"`
+}
+
+type CreateOrganizationDTO struct {
+	Name string `json:"name"`
+}
+
+type UpdateMemberDTO struct {
+	Role string `json:"role"`
+}
+
+type InviteMemberDTO struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// AcceptInvitationDTO accepts an invitation. Name and Password are only
+// needed when no account exists for the invited address yet.
+type AcceptInvitationDTO struct {
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+type MagicLinkSignInDTO struct {
+	Token     string `json:"token"`
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+type OIDCCallbackDTO struct {
+	Provider  string
+	Code      string
+	State     string
+	Error     string
+	IP        string
+	UserAgent string
+}
+
+type MFACodeDTO struct {
+	Code string `json:"code"`
+}
+
+type MFASignInDTO struct {
+	MFAToken  string `json:"mfa_token"`
+	Code      string `json:"code"`
+	IP        string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// Tokens is either a token pair or, for accounts with two-factor
+// authentication, an MFA challenge to be completed with a code.
+type Tokens struct {
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+type Config struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	VerificationTokenTTL time.Duration
+	MailResendInterval   time.Duration
+	RequireVerifiedEmail bool
+
+	PasswordResetTokenTTL time.Duration
+
+	// SignUpDisabled turns off open sign-up, both through SignUp and through
+	// a first sign-in with an identity provider. New users then join through
+	// invitations.
+	SignUpDisabled bool
+	InvitationTTL  time.Duration
+	// InvitationURL, when set, is the page the emailed invitation points to;
+	// the token is appended as the token query parameter.
+	InvitationURL string
+
+	// PasswordPolicy screens new passwords on top of the length limits;
+	// nil accepts any password of valid length.
+	PasswordPolicy validator.PasswordPolicy
+
+	MaxFailedSignIns      int
+	MaxFailedSignInsPerIP int
+	LockoutDuration       time.Duration
+	MaxLockoutDuration    time.Duration
+	AccountUnlockTokenTTL time.Duration
+
+	MagicLinkTTL time.Duration
+	// MagicLinkURL, when set, is the page the emailed link points to; the
+	// token is appended as the token query parameter.
+	MagicLinkURL string
+
+	// OIDCProviders are the external identity providers users can sign in
+	// with, by name.
+	OIDCProviders map[string]OIDCProvider
+	OIDCStateTTL  time.Duration
+
+	// PrivacyServices are the other services holding personal data that
+	// export and erasure requests are forwarded to, by name.
+	PrivacyServices     map[string]PrivacyService
+	PrivacyPollInterval time.Duration
+	// PrivacyArchiveTTL is how long the archive of a data export can be
+	// downloaded before it is deleted.
+	PrivacyArchiveTTL time.Duration
+
+	MFAIssuer              string
+	MFAChallengeTTL        time.Duration
+	MFARequiredPermissions []string
+	// MFASecrets encrypts TOTP secrets before they are stored.
+	MFASecrets *totp.SecretBox
 }
 
 type UserService interface {
 	SignUp(ctx context.Context, user UserSignUpDTO) error
-	SignIn(ctx context.Context, user UserSignInDTO) (Token, error)
+	SignIn(ctx context.Context, user UserSignInDTO) (Tokens, error)
+	Refresh(ctx context.Context, refreshToken string) (Tokens, error)
+	RequestMagicLink(ctx context.Context, email string) error
+	SignInWithMagicLink(ctx context.Context, input MagicLinkSignInDTO) (Tokens, error)
+	BeginOIDCLogin(ctx context.Context, provider string) (string, error)
+	CompleteOIDCLogin(ctx context.Context, input OIDCCallbackDTO) (Tokens, error)
+	Logout(ctx context.Context, refreshToken string) error
+	LogoutAll(ctx context.Context, userID int64) error
+
+	ListRoles(ctx context.Context) ([]*domain.Role, error)
+	GetUserRoles(ctx context.Context, userID int64) ([]string, error)
+	AssignRole(ctx context.Context, actorID, userID int64, role string) error
+	RevokeRole(ctx context.Context, actorID, userID int64, role string) error
+
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, input ResetPasswordDTO) error
+
+	UnlockAccount(ctx context.Context, token string) error
+
+	EnrollTOTP(ctx context.Context, userID int64) (TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID int64, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID int64, code string) error
+	CompleteMFASignIn(ctx context.Context, input MFASignInDTO) (Tokens, error)
+
+	GetProfile(ctx context.Context, userID int64) (*domain.User, error)
+	UpdateProfile(ctx context.Context, userID int64, input UpdateProfileDTO) (*domain.User, error)
+	ChangeEmail(ctx context.Context, userID int64, input ChangeEmailDTO) error
+	ChangePassword(ctx context.Context, userID int64, input ChangePasswordDTO) error
+	DeleteAccount(ctx context.Context, userID int64, password string) error
+
+	GetUser(ctx context.Context, userID int64) (*domain.User, error)
+	GetUsersByIDs(ctx context.Context, ids []int64) ([]*domain.User, error)
+	ListUsers(ctx context.Context, search string, filters repository.Filters) ([]*domain.User, error)
+	ValidateToken(ctx context.Context, accessToken string) (*token.Claims, error)
+
+	DisableUser(ctx context.Context, actorID, userID int64) error
+	EnableUser(ctx context.Context, actorID, userID int64) error
+	ForcePasswordReset(ctx context.Context, actorID, userID int64) error
+	GetAuditLog(ctx context.Context, userID int64, filters repository.Filters) ([]*domain.AuditEvent, error)
+
+	CreateAPIKey(ctx context.Context, userID int64, input CreateAPIKeyDTO) (string, *domain.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID int64) ([]*domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID int64) error
+	ResolveAPIKey(ctx context.Context, key string) (token.Identity, error)
+
+	ListSessions(ctx context.Context, userID, currentSessionID int64) ([]*domain.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID int64) error
+	SessionActive(ctx context.Context, accessToken string, identity token.Identity) (bool, error)
+
+	CreateOrganization(ctx context.Context, userID int64, input CreateOrganizationDTO) (*domain.Organization, error)
+	ListOrganizations(ctx context.Context, userID int64) ([]*domain.Organization, error)
+	GetOrganization(ctx context.Context, userID, orgID int64) (*domain.Organization, error)
+	ListMembers(ctx context.Context, userID, orgID int64) ([]*domain.OrganizationMember, error)
+	UpdateMemberRole(ctx context.Context, actorID, orgID, userID int64, role string) error
+	RemoveMember(ctx context.Context, actorID, orgID, userID int64) error
+	SwitchOrganization(ctx context.Context, userID, sessionID, orgID int64) (Tokens, error)
+
+	InviteMember(ctx context.Context, actorID, orgID int64, input InviteMemberDTO) (*domain.Invitation, error)
+	ListInvitations(ctx context.Context, actorID, orgID int64) ([]*domain.Invitation, error)
+	RevokeInvitation(ctx context.Context, actorID, orgID, invitationID int64) error
+	AcceptInvitation(ctx context.Context, input AcceptInvitationDTO) (*domain.Organization, error)
+
+	RequestDataExport(ctx context.Context, actorID, userID int64) (*domain.PrivacyRequest, error)
+	RequestErasure(ctx context.Context, actorID, userID int64) (*domain.PrivacyRequest, error)
+	RequestOwnErasure(ctx context.Context, userID int64, password string) (*domain.PrivacyRequest, error)
+	GetPrivacyRequest(ctx context.Context, id int64) (*domain.PrivacyRequest, error)
+	GetPrivacyArchive(ctx context.Context, id int64) ([]byte, error)
 }
 
 type service struct {
-	repo         repository.User
-	hasher       hash.PasswordHasher
-	tokenManager token.TokenManager
+	repos         repository.Repositories
+	repo          repository.User
+	refreshTokens repository.RefreshToken
+	roles         repository.Role
+	userTokens    repository.UserToken
+	loginFailures repository.LoginFailure
+	mfa           repository.MFA
+	audit         repository.Audit
+	apiKeys       repository.APIKey
+	sessions      repository.Session
+	oidc          repository.OIDC
+	privacy       repository.Privacy
+	orgs          repository.Organization
+	invitations   repository.Invitation
+	hasher        hash.PasswordHasher
+	tokenManager  token.TokenManager
+	mailer        mailer.Mailer
+	config        Config
+
+	dummyHashOnce  sync.Once
+	dummyHashValue string
 }
 
-func New(repo repository.User, hasher hash.PasswordHasher, tokenManager token.TokenManager) *service {
+func New(repos repository.Repositories, hasher hash.PasswordHasher, tokenManager token.TokenManager, mailer mailer.Mailer, cfg Config) *service {
 	return &service{
-		repo:         repo,
-		hasher:       hasher,
-		tokenManager: tokenManager,
+		repos:         repos,
+		repo:          repos.Users,
+		refreshTokens: repos.RefreshTokens,
+		roles:         repos.Roles,
+		userTokens:    repos.UserTokens,
+		loginFailures: repos.LoginFailures,
+		mfa:           repos.MFA,
+		audit:         repos.Audit,
+		apiKeys:       repos.APIKeys,
+		sessions:      repos.Sessions,
+		oidc:          repos.OIDC,
+		privacy:       repos.Privacy,
+		orgs:          repos.Organizations,
+		invitations:   repos.Invitations,
+		hasher:        hasher,
+		tokenManager:  tokenManager,
+		mailer:        mailer,
+		config:        cfg,
 	}
 }
 
+// inTx runs fn with a copy of the service whose repositories share one
+// transaction, so that its changes are made all together or not at all.
+// Mail should be sent after inTx returns, once the changes are committed.
+func (s *service) inTx(ctx context.Context, fn func(tx *service) error) error {
+	return s.repos.Transaction(ctx, func(repos repository.Repositories) error {
+		return fn(New(repos, s.hasher, s.tokenManager, s.mailer, s.config))
+	})
+}
+
 func (s *service) SignUp(ctx context.Context, input UserSignUpDTO) error {
-	passwordHash, err := s.hasher.Hash(input.HashPassword)
-	if err != nil {
-		return err
+	if s.config.SignUpDisabled {
+		return ErrSignUpDisabled
 	}
 
+	_, err := s.signUp(ctx, input, false)
+	return err
+}
+
+// signUp creates an account with a password. Invited users have proven they
+// own the address by receiving the invitation, so it is verified straight
+// away, and they get no personal organization as they are joining one.
+func (s *service) signUp(ctx context.Context, input UserSignUpDTO, invited bool) (*domain.User, error) {
 	user := domain.User{
 		Name:         input.Name,
 		Email:        input.Email,
-		HashPassword: passwordHash,
+		HashPassword: input.HashPassword,
 	}
 
 	v := validator.New()
 	validateUser(v, &user)
 	if !v.Valid() {
-		return ErrFailedValidation
+		return nil, ErrFailedValidation
 	}
 
-	err = s.repo.Insert(ctx, &user)
+	err := s.checkPasswordPolicy(input.HashPassword, input.Name, input.Email)
 	if err != nil {
-		switch {
-		case errors.Is(err, repository.ErrDuplicate):
-			return ErrDuplicate
-		default:
+		return nil, err
+	}
+
+	passwordHash, err := s.hasher.Hash(input.HashPassword)
+	if err != nil {
+		return nil, err
+	}
+	user.HashPassword = passwordHash
+
+	// The account is only usable with its role and organization, so it is
+	// created together with them or not at all.
+	err = s.inTx(ctx, func(tx *service) error {
+		err := tx.repo.Insert(ctx, &user)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrDuplicate):
+				return ErrDuplicate
+			default:
+				return err
+			}
+		}
+
+		err = tx.roles.AddForUser(ctx, user.ID, DefaultRole)
+		if err != nil {
 			return err
 		}
+
+		if invited {
+			return tx.repo.MarkEmailVerified(ctx, user.ID)
+		}
+		return tx.createPersonalOrganization(ctx, &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if invited {
+		return &user, nil
 	}
-	return nil
 
+	// The account exists at this point; a failed delivery can be retried
+	// through the resend endpoint.
+	if err := s.sendEmailVerification(ctx, &user); err != nil {
+		log.Printf("send verification email to user %d: %v", user.ID, err)
+	}
+	return &user, nil
 }
 
-func (s *service) SignIn(ctx context.Context, input UserSignInDTO) (Token, error) {
-	user, err := s.repo.GetByEmail(ctx, input.Email)
+func (s *service) SignIn(ctx context.Context, input UserSignInDTO) (Tokens, error) {
+	keys := signInKeys(input.Email, input.IP)
+
+	locked, err := s.lockedOut(ctx, keys)
 	if err != nil {
-		switch {
-		case errors.Is(err, repository.ErrRecordNotFound):
-			return Token{}, ErrWrongCredentials
-		default:
-			return Token{}, err
+		return Tokens{}, err
+	}
+	if locked {
+		return Tokens{}, ErrRateLimited
+	}
+
+	user, err := s.authenticate(ctx, input.Email, input.HashPassword)
+	if err != nil {
+		if errors.Is(err, ErrWrongCredentials) {
+			if err := s.recordFailedSignIn(ctx, keys, input.Email); err != nil {
+				return Tokens{}, err
+			}
 		}
+		return Tokens{}, err
+	}
+
+	err = s.loginFailures.Reset(ctx, keys.email)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if err := checkAccountStatus(user); err != nil {
+		return Tokens{}, err
+	}
+
+	if s.config.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		return Tokens{}, ErrEmailNotVerified
+	}
+
+	if s.hasher.NeedsRehash(user.HashPassword) {
+		s.rehashPassword(ctx, user.ID, input.HashPassword)
 	}
 
-	err2 := bcrypt.CompareHashAndPassword([]byte(user.HashPassword), []byte(input.HashPassword))
-	if err2 != nil {
+	mfaEnabled, err := s.mfaEnabled(ctx, user.ID)
+	if err != nil {
+		return Tokens{}, err
+	}
+	if mfaEnabled {
+		return s.issueMFAChallenge(ctx, user.ID)
+	}
+
+	return s.startSession(ctx, user.ID, input.UserAgent, input.IP)
+
+}
+
+// authenticate checks the password of the account registered under email.
+// Unknown addresses still cost one hash verification so that response times
+// do not reveal which accounts exist.
+func (s *service) authenticate(ctx context.Context, email, password string) (*domain.User, error) {
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
 		switch {
-		case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
-			return Token{}, nil
+		case errors.Is(err, repository.ErrRecordNotFound):
+			s.hasher.Verify(password, s.dummyHash())
+			return nil, ErrWrongCredentials
 		default:
-			return Token{}, err
+			return nil, err
 		}
 	}
 
-	token, err := s.tokenManager.NewToken(user.ID, time.Duration(12)*time.Hour)
-	return Token{PlainText: token}, err
+	// Accounts created through an identity provider have no password.
+	if user.HashPassword == "" {
+		s.hasher.Verify(password, s.dummyHash())
+		return nil, ErrWrongCredentials
+	}
+
+	match, err := s.hasher.Verify(password, user.HashPassword)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, ErrWrongCredentials
+	}
+	return user, nil
+}
+
+// rehashPassword upgrades a stored hash to the current algorithm and cost.
+// Failures are only logged: the user has already proven the password.
+func (s *service) rehashPassword(ctx context.Context, userID int64, password string) {
+	passwordHash, err := s.hasher.Hash(password)
+	if err == nil {
+		err = s.repo.UpdatePasswordHash(ctx, userID, passwordHash)
+	}
+	if err != nil {
+		log.Printf("rehash password for user %d: %v", userID, err)
+	}
+}
+
+// checkAccountStatus rejects accounts an administrator has disabled or
+// flagged for a password reset.
+func checkAccountStatus(user *domain.User) error {
+	switch {
+	case user.DisabledAt != nil:
+		return ErrAccountDisabled
+	case user.PasswordResetRequired:
+		return ErrPasswordResetRequired
+	default:
+		return nil
+	}
+}
+
+// checkPasswordPolicy runs password, already known to be of valid length,
+// through the configured policy. userInputs are the name and email address
+// of the account.
+func (s *service) checkPasswordPolicy(password string, userInputs ...string) error {
+	if s.config.PasswordPolicy == nil {
+		return nil
+	}
+
+	v := validator.New()
 
+	err := s.config.PasswordPolicy.ValidatePassword(v, password, userInputs...)
+	if err != nil {
+		return err
+	}
+	if !v.Valid() {
+		return PasswordRejectedError{Reason: v.Errors["password"]}
+	}
+	return nil
 }
 
 func validateEmail(v *validator.Validator, email string) {
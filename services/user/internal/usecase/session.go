@@ -0,0 +1,110 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"microservices/pkg/token"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+	"strings"
+	"time"
+)
+
+// startSession records a new sign-in and issues its first token pair.
+func (s *service) startSession(ctx context.Context, userID int64, userAgent, ip string) (Tokens, error) {
+	familyID, err := newFamilyID()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	session := domain.Session{
+		UserID:    userID,
+		FamilyID:  familyID,
+		UserAgent: truncate(userAgent, 512),
+		IP:        ip,
+	}
+
+	// New sessions act in the user's oldest organization until they
+	// switch.
+	member, err := s.orgs.GetDefaultMembership(ctx, userID)
+	switch {
+	case err == nil:
+		session.OrgID = &member.OrgID
+	case !errors.Is(err, repository.ErrRecordNotFound):
+		return Tokens{}, err
+	}
+
+	err = s.sessions.Insert(ctx, &session)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return s.issueTokens(ctx, &session)
+}
+
+func (s *service) ListSessions(ctx context.Context, userID, currentSessionID int64) ([]*domain.Session, error) {
+	sessions, err := s.sessions.GetActiveForUser(ctx, userID, time.Now().Add(-s.config.RefreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs the user out on one device. Its refresh tokens stop
+// working at once; its access tokens as soon as they are next checked.
+func (s *service) RevokeSession(ctx context.Context, userID, sessionID int64) error {
+	familyID, err := s.sessions.Revoke(ctx, sessionID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return s.refreshTokens.RevokeFamily(ctx, familyID)
+}
+
+// SessionActive implements token.SessionChecker for the user service's own
+// middleware.
+func (s *service) SessionActive(ctx context.Context, accessToken string, identity token.Identity) (bool, error) {
+	session, err := s.sessions.GetByID(ctx, identity.SessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+	return session.UserID == identity.UserID && session.RevokedAt == nil, nil
+}
+
+func (s *service) revokeFamily(ctx context.Context, familyID string) error {
+	err := s.sessions.RevokeFamily(ctx, familyID)
+	if err != nil {
+		return err
+	}
+	return s.refreshTokens.RevokeFamily(ctx, familyID)
+}
+
+// revokeAllSessions signs the user out everywhere.
+func (s *service) revokeAllSessions(ctx context.Context, userID int64) error {
+	err := s.sessions.RevokeAllForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.refreshTokens.RevokeAllForUser(ctx, userID)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
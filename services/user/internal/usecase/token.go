@@ -0,0 +1,261 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"microservices/pkg/token"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+	"time"
+)
+
+func (s *service) Refresh(ctx context.Context, refreshToken string) (Tokens, error) {
+	hash := token.HashOpaqueToken(refreshToken)
+
+	stored, err := s.refreshTokens.GetByHash(ctx, hash)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return Tokens{}, ErrInvalidRefreshToken
+		default:
+			return Tokens{}, err
+		}
+	}
+
+	if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	// A token that was already rotated is being replayed: whoever holds it
+	// may not be the legitimate client, so the whole family is revoked.
+	if stored.UsedAt != nil {
+		return Tokens{}, s.revokeReusedFamily(ctx, stored.FamilyID)
+	}
+
+	err = s.refreshTokens.MarkUsed(ctx, hash)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			return Tokens{}, s.revokeReusedFamily(ctx, stored.FamilyID)
+		default:
+			return Tokens{}, err
+		}
+	}
+
+	session, err := s.sessions.GetByFamilyID(ctx, stored.FamilyID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return Tokens{}, ErrInvalidRefreshToken
+		default:
+			return Tokens{}, err
+		}
+	}
+
+	if session.RevokedAt != nil {
+		return Tokens{}, ErrInvalidRefreshToken
+	}
+
+	err = s.sessions.Touch(ctx, session.ID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return s.issueTokens(ctx, session)
+}
+
+func (s *service) Logout(ctx context.Context, refreshToken string) error {
+	stored, err := s.refreshTokens.GetByHash(ctx, token.HashOpaqueToken(refreshToken))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return ErrInvalidRefreshToken
+		default:
+			return err
+		}
+	}
+
+	return s.revokeFamily(ctx, stored.FamilyID)
+}
+
+func (s *service) LogoutAll(ctx context.Context, userID int64) error {
+	return s.revokeAllSessions(ctx, userID)
+}
+
+func (s *service) issueTokens(ctx context.Context, session *domain.Session) (Tokens, error) {
+	accessToken, err := s.accessToken(ctx, session)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	plaintext, hash, err := token.NewOpaqueToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	refreshToken := domain.RefreshToken{
+		Hash:      hash,
+		UserID:    session.UserID,
+		FamilyID:  session.FamilyID,
+		ExpiresAt: time.Now().Add(s.config.RefreshTokenTTL),
+	}
+
+	err = s.refreshTokens.Insert(ctx, &refreshToken)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{AccessToken: accessToken, RefreshToken: plaintext}, nil
+}
+
+// accessToken signs a token for the session, bound to the session's
+// organization as long as the user is still a member of it.
+func (s *service) accessToken(ctx context.Context, session *domain.Session) (string, error) {
+	identity, err := s.identity(ctx, session.UserID)
+	if err != nil {
+		return "", err
+	}
+	identity.SessionID = session.ID
+
+	if session.OrgID != nil {
+		err = s.withOrganization(ctx, &identity, *session.OrgID)
+		if err != nil && !errors.Is(err, ErrRecordNotFound) {
+			return "", err
+		}
+	}
+
+	return s.tokenManager.NewToken(identity, s.config.AccessTokenTTL)
+}
+
+// withOrganization sets the organization claims of identity, or returns
+// ErrRecordNotFound if the user is not a member.
+func (s *service) withOrganization(ctx context.Context, identity *token.Identity, orgID int64) error {
+	member, err := s.membership(ctx, orgID, identity.UserID)
+	if err != nil {
+		return err
+	}
+	identity.OrgID = member.OrgID
+	identity.OrgRole = member.Role
+	return nil
+}
+
+// identity loads the claims embedded in access tokens from the current
+// state of the user's roles, so role changes apply on the next refresh.
+func (s *service) identity(ctx context.Context, userID int64) (token.Identity, error) {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return token.Identity{}, err
+	}
+
+	if user.DisabledAt != nil {
+		return token.Identity{}, ErrAccountDisabled
+	}
+
+	roles, err := s.roles.GetAllForUser(ctx, userID)
+	if err != nil {
+		return token.Identity{}, err
+	}
+
+	permissions, err := s.roles.GetPermissionsForUser(ctx, userID)
+	if err != nil {
+		return token.Identity{}, err
+	}
+
+	permissions, err = s.withoutMFAOnlyPermissions(ctx, userID, permissions)
+	if err != nil {
+		return token.Identity{}, err
+	}
+
+	return token.Identity{
+		UserID:      user.ID,
+		Email:       user.Email,
+		Roles:       roles,
+		Permissions: permissions,
+	}, nil
+}
+
+func (s *service) revokeReusedFamily(ctx context.Context, familyID string) error {
+	err := s.revokeFamily(ctx, familyID)
+	if err != nil {
+		return err
+	}
+	return ErrInvalidRefreshToken
+}
+
+// issueUserToken stores a new single-use token for the given scope and
+// returns its plaintext for delivery to the user.
+func (s *service) issueUserToken(ctx context.Context, userID int64, scope string, ttl time.Duration) (string, error) {
+	plaintext, hash, err := token.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	userToken := domain.UserToken{
+		Hash:      hash,
+		UserID:    userID,
+		Scope:     scope,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	err = s.userTokens.Insert(ctx, &userToken)
+	if err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+// consumeUserToken exchanges a single-use token for the ID of its owner.
+func (s *service) consumeUserToken(ctx context.Context, scope, plaintext string) (int64, error) {
+	userID, err := s.userTokens.Consume(ctx, scope, token.HashOpaqueToken(plaintext))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return 0, ErrInvalidToken
+		default:
+			return 0, err
+		}
+	}
+	return userID, nil
+}
+
+// lookupUserToken is consumeUserToken without using the token up, for
+// checks that must pass before it is spent.
+func (s *service) lookupUserToken(ctx context.Context, scope, plaintext string) (int64, error) {
+	userID, err := s.userTokens.Lookup(ctx, scope, token.HashOpaqueToken(plaintext))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return 0, ErrInvalidToken
+		default:
+			return 0, err
+		}
+	}
+	return userID, nil
+}
+
+// throttled reports whether a token for scope was issued to the user less
+// than interval ago.
+func (s *service) throttled(ctx context.Context, scope string, userID int64, interval time.Duration) (bool, error) {
+	latest, err := s.userTokens.GetLatestForUser(ctx, scope, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+	return time.Since(latest.CreatedAt) < interval, nil
+}
+
+func newFamilyID() (string, error) {
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(randomBytes), nil
+}
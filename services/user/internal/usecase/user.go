@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"microservices/pkg/token"
+	"microservices/pkg/validator"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+)
+
+// maxUsersByIDs bounds a single batch lookup.
+const maxUsersByIDs = 100
+
+func (s *service) GetUser(ctx context.Context, userID int64) (*domain.User, error) {
+	return s.getUser(ctx, userID)
+}
+
+func (s *service) GetUsersByIDs(ctx context.Context, ids []int64) ([]*domain.User, error) {
+	v := validator.New()
+	v.Check(len(ids) > 0, "ids", "must contain at least one id")
+	v.Check(len(ids) <= maxUsersByIDs, "ids", "must not contain more than 100 ids")
+	if !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	return s.repo.GetByIDs(ctx, ids)
+}
+
+func (s *service) ListUsers(ctx context.Context, search string, filters repository.Filters) ([]*domain.User, error) {
+	v := validator.New()
+	if repository.ValidateFilters(v, filters); !v.Valid() {
+		return nil, ErrFailedValidation
+	}
+
+	return s.repo.GetAll(ctx, search, filters)
+}
+
+// ValidateToken parses an access token and checks that its subject still
+// exists and is not disabled and that its session has not been revoked, so
+// other services can reject such tokens before they expire.
+func (s *service) ValidateToken(ctx context.Context, accessToken string) (*token.Claims, error) {
+	claims, err := s.tokenManager.Parse(accessToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, token.ErrInvalidToken), errors.Is(err, token.ErrExpiredToken):
+			return nil, ErrInvalidToken
+		default:
+			return nil, err
+		}
+	}
+
+	user, err := s.getUser(ctx, claims.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return nil, ErrInvalidToken
+		default:
+			return nil, err
+		}
+	}
+
+	if user.DisabledAt != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.SessionID != 0 {
+		active, err := s.SessionActive(ctx, accessToken, claims.Identity)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, ErrInvalidToken
+		}
+	}
+	return claims, nil
+}
@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"microservices/pkg/mailer"
+	"microservices/pkg/validator"
+	"microservices/services/user/internal/domain"
+	"microservices/services/user/internal/repository"
+)
+
+func (s *service) VerifyEmail(ctx context.Context, token string) error {
+	userID, err := s.consumeUserToken(ctx, repository.ScopeEmailVerification, token)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.MarkEmailVerified(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.userTokens.DeleteAllForUser(ctx, repository.ScopeEmailVerification, userID)
+}
+
+// ResendVerification succeeds silently for unknown or already verified
+// addresses, for repeated requests inside the resend interval and when the
+// mail cannot be delivered, so the endpoint cannot be used to enumerate
+// accounts.
+func (s *service) ResendVerification(ctx context.Context, email string) error {
+	v := validator.New()
+	if validateEmail(v, email); !v.Valid() {
+		return ErrFailedValidation
+	}
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			return nil
+		default:
+			return err
+		}
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	throttled, err := s.throttled(ctx, repository.ScopeEmailVerification, user.ID, s.config.MailResendInterval)
+	if err != nil || throttled {
+		return err
+	}
+
+	if err := s.sendEmailVerification(ctx, user); err != nil {
+		log.Printf("send verification email to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+func (s *service) sendEmailVerification(ctx context.Context, user *domain.User) error {
+	token, err := s.issueUserToken(ctx, user.ID, repository.ScopeEmailVerification, s.config.VerificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm your email address by sending the token below to POST /v1/user/verify:\n\n"+
+			"{\"token\": \"%s\"}\n\n"+
+			"The token expires in %s.\n", user.Name, token, s.config.VerificationTokenTTL),
+	})
+}